@@ -0,0 +1,287 @@
+package gopastemyst
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sammie156/go-pastemyst/internal/fileutil"
+)
+
+// ErrNoToken is returned by requests that need an API token when the client
+// has none.
+var ErrNoToken = errors.New("please provide API token")
+
+// TokenSource provides the API token sent with requests. It is asked for a
+// token on every request, so it can rotate tokens. An empty token means the
+// request is sent anonymously. Implementations must be safe for concurrent
+// use.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource always returning the same token.
+type StaticToken string
+
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// Anonymous is a TokenSource sending every request without credentials.
+var Anonymous TokenSource = StaticToken("")
+
+// WithTokenSource makes the client get its API token from source instead of
+// the token given to NewClient. Use Anonymous, or nil, to never send one.
+func WithTokenSource(source TokenSource) ClientOption {
+	return func(c *Client) {
+		if source == nil {
+			source = Anonymous
+		}
+		c.tokens = source
+	}
+}
+
+// WithAnonymous makes the client send every request without credentials.
+func WithAnonymous() ClientOption {
+	return WithTokenSource(Anonymous)
+}
+
+type envToken string
+
+// EnvToken returns a TokenSource reading the token from the environment
+// variable name on every request. An unset or empty variable is an error
+// rather than silently sending requests anonymously.
+func EnvToken(name string) TokenSource {
+	return envToken(name)
+}
+
+func (e envToken) Token(context.Context) (string, error) {
+	token := strings.TrimSpace(os.Getenv(string(e)))
+	if token == "" {
+		return "", fmt.Errorf("environment variable %s is not set", string(e))
+	}
+
+	return token, nil
+}
+
+// FileToken is a TokenSource reading the token from a file. The file is read
+// again whenever it changes, so the token can be rotated without restarting.
+type FileToken struct {
+	watched fileWatch
+}
+
+// NewFileToken creates a FileToken for the file at path, which holds the
+// token alone, surrounding whitespace ignored.
+func NewFileToken(path string) *FileToken {
+	return &FileToken{watched: fileWatch{
+		path: path,
+		parse: func(data []byte) (string, error) {
+			return strings.TrimSpace(string(data)), nil
+		},
+	}}
+}
+
+func (f *FileToken) Token(context.Context) (string, error) {
+	return f.watched.value()
+}
+
+// Parameters of tokens written by WriteEncryptedToken
+const (
+	encryptedTokenVersion    = 1
+	encryptedTokenIterations = 600_000
+)
+
+// encryptedToken is the format of the files read by EncryptedFileToken. The
+// token is encrypted with AES-256-GCM, with the key derived from a
+// passphrase using PBKDF2-HMAC-SHA256.
+type encryptedToken struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptedFileToken is a TokenSource reading the token from a file written
+// by WriteEncryptedToken, for machines without an OS keyring. Like FileToken,
+// the file is read again whenever it changes.
+type EncryptedFileToken struct {
+	watched fileWatch
+}
+
+// NewEncryptedFileToken creates an EncryptedFileToken for the file at path,
+// decrypted with passphrase.
+func NewEncryptedFileToken(path string, passphrase string) *EncryptedFileToken {
+	return &EncryptedFileToken{watched: fileWatch{
+		path: path,
+		parse: func(data []byte) (string, error) {
+			return decryptToken(data, passphrase)
+		},
+	}}
+}
+
+func (e *EncryptedFileToken) Token(context.Context) (string, error) {
+	return e.watched.value()
+}
+
+// WriteEncryptedToken encrypts token with passphrase and writes it to path,
+// readable only by the current user.
+func WriteEncryptedToken(path string, token string, passphrase string) error {
+	file := encryptedToken{
+		Version:    encryptedTokenVersion,
+		Iterations: encryptedTokenIterations,
+		Salt:       make([]byte, 16),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return fmt.Errorf("could not generate salt: %w", err)
+	}
+
+	aead, err := tokenCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("could not generate nonce: %w", err)
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, []byte(token), nil)
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("could not encode token file: %w", err)
+	}
+
+	if err := fileutil.WriteFileAtomic(filepath.Dir(path), path, data); err != nil {
+		return fmt.Errorf("could not write token file: %w", err)
+	}
+
+	return nil
+}
+
+func decryptToken(data []byte, passphrase string) (string, error) {
+	var file encryptedToken
+	if err := json.Unmarshal(data, &file); err != nil {
+		return "", fmt.Errorf("could not decode token file: %w", err)
+	}
+	if file.Version != encryptedTokenVersion {
+		return "", fmt.Errorf("unsupported token file version %d", file.Version)
+	}
+
+	aead, err := tokenCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return "", err
+	}
+
+	if len(file.Nonce) != aead.NonceSize() {
+		return "", errors.New("token file is corrupted")
+	}
+
+	token, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return "", errors.New("could not decrypt token file, wrong passphrase?")
+	}
+
+	return string(token), nil
+}
+
+func tokenCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("could not derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// fileWatch caches a value parsed from a file, parsing it again when the
+// file's size or modification time change.
+type fileWatch struct {
+	path  string
+	parse func(data []byte) (string, error)
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	cached  string
+	loaded  bool
+}
+
+func (f *fileWatch) value() (string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", fmt.Errorf("could not read token file: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.loaded && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.cached, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", fmt.Errorf("could not read token file: %w", err)
+	}
+
+	value, err := f.parse(data)
+	if err != nil {
+		return "", err
+	}
+
+	f.cached, f.modTime, f.size, f.loaded = value, info.ModTime(), info.Size(), true
+
+	return value, nil
+}
+
+// newRequest creates a request with the client's credentials and the call's
+// headers attached. Every request goes through it, so credentials are handled
+// the same everywhere. With requireAuth set, ErrNoToken is returned when there
+// is no token.
+func (c *Client) newRequest(ctx context.Context, method string, url string, body io.Reader, requireAuth bool, opts []RequestOption) (*http.Request, error) {
+	token, err := c.requestToken(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" && requireAuth {
+		return nil, ErrNoToken
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	cfg := newRequestConfig(opts)
+	for key, values := range cfg.header {
+		req.Header[key] = values
+	}
+	if cfg.idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", cfg.idempotencyKey)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,359 @@
+package gopastemyst
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sammie156/go-pastemyst/internal/fileutil"
+)
+
+// BackupFormatVersion is written to every backup manifest so future versions
+// of the library can tell how an archive is laid out.
+const BackupFormatVersion = 1
+
+// Name of the revision directory holding the latest state of a paste
+const currentRevision = "current"
+
+// A backup archive is a directory laid out as follows, readable only by the
+// current user since it may hold private pastes:
+//
+//	manifest.json                              BackupManifest describing everything below
+//	pastes/<id>/paste.json                     latest Paste as returned by the API
+//	pastes/<id>/stats.json                     Stats of the latest revision
+//	pastes/<id>/history.json                   compact history of the paste
+//	pastes/<id>/revisions/<rev>/paste.json     Paste at that revision
+//	pastes/<id>/revisions/<rev>/<nn>-<title>   content of each pasty at that revision
+//
+// <rev> is a history ID, or "current" for the latest revision.
+
+type BackupManifest struct {
+	Version   int                         `json:"version"`
+	Username  string                      `json:"username"`
+	UpdatedAt time.Time                   `json:"updatedAt"`
+	Pastes    map[string]BackupPasteEntry `json:"pastes"`
+}
+
+type BackupPasteEntry struct {
+	Title      string           `json:"title"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	EditedAt   *time.Time       `json:"editedAt"`
+	BackedUpAt time.Time        `json:"backedUpAt"`
+	Revisions  []BackupRevision `json:"revisions"`
+}
+
+type BackupRevision struct {
+	ID       string            `json:"id"`
+	EditedAt *time.Time        `json:"editedAt"`
+	Pasties  []BackupPastyFile `json:"pasties"`
+}
+
+// BackupPastyFile links a pasty to the file holding its content, relative to
+// the revision directory.
+type BackupPastyFile struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Language string `json:"language"`
+	File     string `json:"file"`
+}
+
+type BackupOptions struct {
+	// Skip pastes whose EditedAt matches the one recorded in an existing
+	// manifest in the backup directory
+	Incremental bool
+}
+
+type BackupReport struct {
+	Saved   []string
+	Skipped []string
+	Failed  map[string]error
+}
+
+// BackupUser writes every paste of username, with its stats and full edit
+// history, to the archive directory dir. Pastes that fail to back up are
+// listed in the report and do not stop the others.
+func (c *Client) BackupUser(ctx context.Context, username string, dir string, options BackupOptions, opts ...RequestOption) (*BackupReport, error) {
+	pastes, err := c.GetAllUserPastes(ctx, username, "", opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not list pastes of %s: %w", username, err)
+	}
+
+	manifest := &BackupManifest{
+		Version:  BackupFormatVersion,
+		Username: username,
+		Pastes:   make(map[string]BackupPasteEntry),
+	}
+
+	if options.Incremental {
+		existing, err := ReadBackupManifest(dir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if existing != nil {
+			manifest.Pastes = existing.Pastes
+		}
+	}
+
+	report := &BackupReport{Failed: make(map[string]error)}
+
+	for _, listed := range pastes {
+		if previous, ok := manifest.Pastes[listed.ID]; ok && options.Incremental && sameTime(previous.EditedAt, listed.EditedAt) {
+			report.Skipped = append(report.Skipped, listed.ID)
+			continue
+		}
+
+		entry, err := c.backupPaste(ctx, filepath.Join(dir, "pastes", listed.ID), listed.ID, opts)
+		if err != nil {
+			report.Failed[listed.ID] = err
+			continue
+		}
+
+		manifest.Pastes[listed.ID] = *entry
+		report.Saved = append(report.Saved, listed.ID)
+	}
+
+	manifest.UpdatedAt = time.Now().UTC()
+	if err := writeJSONFile(filepath.Join(dir, "manifest.json"), manifest); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// backupPaste saves pasteID in dir. It bypasses the response cache, so the
+// backup never holds a stale copy.
+func (c *Client) backupPaste(ctx context.Context, dir string, pasteID string, opts []RequestOption) (*BackupPasteEntry, error) {
+	paste, err := c.fetchPaste(ctx, pasteID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := c.fetchPasteStats(ctx, pasteID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := c.GetCompactPasteHistory(ctx, pasteID, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range map[string]any{"paste.json": paste, "stats.json": stats, "history.json": history} {
+		if err := writeJSONFile(filepath.Join(dir, name), value); err != nil {
+			return nil, err
+		}
+	}
+
+	entry := &BackupPasteEntry{
+		Title:      paste.Title,
+		CreatedAt:  paste.CreatedAt,
+		EditedAt:   paste.EditedAt,
+		BackedUpAt: time.Now().UTC(),
+	}
+
+	current, err := writeRevision(filepath.Join(dir, "revisions", currentRevision), currentRevision, paste)
+	if err != nil {
+		return nil, err
+	}
+	entry.Revisions = append(entry.Revisions, *current)
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].EditedAt.Before(history[j].EditedAt)
+	})
+
+	for _, edit := range history {
+		old, err := c.GetPasteAtSpecificEdit(ctx, pasteID, edit.ID, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch revision %s: %w", edit.ID, err)
+		}
+
+		revision, err := writeRevision(filepath.Join(dir, "revisions", edit.ID), edit.ID, old)
+		if err != nil {
+			return nil, err
+		}
+
+		editedAt := edit.EditedAt
+		revision.EditedAt = &editedAt
+		entry.Revisions = append(entry.Revisions, *revision)
+	}
+
+	return entry, nil
+}
+
+func writeRevision(dir string, id string, paste *Paste) (*BackupRevision, error) {
+	if err := writeJSONFile(filepath.Join(dir, "paste.json"), paste); err != nil {
+		return nil, err
+	}
+
+	revision := &BackupRevision{ID: id, EditedAt: paste.EditedAt}
+
+	for i, pasty := range paste.Pasties {
+		name := pasty.Title
+		if name == "" {
+			name = pasty.ID
+		}
+		file := fmt.Sprintf("%02d-%s", i, sanitizeFileName(name))
+
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(pasty.Content), 0o600); err != nil {
+			return nil, fmt.Errorf("could not write pasty file: %w", err)
+		}
+
+		revision.Pasties = append(revision.Pasties, BackupPastyFile{
+			ID:       pasty.ID,
+			Title:    pasty.Title,
+			Language: pasty.Language,
+			File:     file,
+		})
+	}
+
+	return revision, nil
+}
+
+// ReadBackupManifest loads the manifest of the backup archive in dir.
+func ReadBackupManifest(dir string) (*BackupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read backup manifest: %w", err)
+	}
+
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("could not decode backup manifest: %w", err)
+	}
+
+	if manifest.Version > BackupFormatVersion {
+		return nil, fmt.Errorf("backup format version %d is not supported", manifest.Version)
+	}
+
+	return &manifest, nil
+}
+
+// RestoreBackup re-creates the latest revision of every paste in the backup
+// archive in dir and returns a mapping from old to new paste IDs. Edit
+// history can't be re-created and is left in the archive. A paste with a
+// pasty file that can't be read is not restored at all, rather than restored
+// without that pasty.
+func (c *Client) RestoreBackup(ctx context.Context, dir string, opts ...RequestOption) (map[string]string, error) {
+	manifest, err := ReadBackupManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(manifest.Pastes))
+	for id := range manifest.Pastes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var pastes []Paste
+	var errs []error
+
+pastes:
+	for _, id := range ids {
+		revisionDir := filepath.Join(dir, "pastes", id, "revisions", currentRevision)
+
+		var paste Paste
+		if err := readJSONFile(filepath.Join(revisionDir, "paste.json"), &paste); err != nil {
+			errs = append(errs, fmt.Errorf("paste %s: %w", id, err))
+			continue
+		}
+
+		// Pasty files are the source of truth, so edits made to them by hand
+		// are restored too
+		for _, revision := range manifest.Pastes[id].Revisions {
+			if revision.ID != currentRevision {
+				continue
+			}
+
+			pasties := make([]Pasty, 0, len(revision.Pasties))
+			for _, file := range revision.Pasties {
+				content, err := os.ReadFile(filepath.Join(revisionDir, file.File))
+				if err != nil {
+					errs = append(errs, fmt.Errorf("paste %s: could not read pasty file: %w", id, err))
+					continue pastes
+				}
+
+				pasties = append(pasties, Pasty{
+					ID:       file.ID,
+					Title:    file.Title,
+					Content:  string(content),
+					Language: file.Language,
+				})
+			}
+			paste.Pasties = pasties
+		}
+
+		pastes = append(pastes, paste)
+	}
+
+	mapping, err := c.RenewPastes(ctx, pastes, RenewOptions{}, opts...)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return mapping, errors.Join(errs...)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return a.Equal(*b)
+}
+
+// sanitizeFileName replaces characters that aren't safe in file names on
+// common filesystems.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		default:
+			return r
+		}
+	}, name)
+
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		return "untitled"
+	}
+
+	return name
+}
+
+func writeJSONFile(path string, value any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("could not create directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %w", filepath.Base(path), err)
+	}
+
+	if err := fileutil.WriteFileAtomic(filepath.Dir(path), path, data); err != nil {
+		return fmt.Errorf("could not write %s: %w", filepath.Base(path), err)
+	}
+
+	return nil
+}
+
+func readJSONFile(path string, value any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", filepath.Base(path), err)
+	}
+
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("could not decode %s: %w", filepath.Base(path), err)
+	}
+
+	return nil
+}
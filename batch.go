@@ -0,0 +1,107 @@
+package gopastemyst
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultBatchConcurrency is the number of workers used by GetPastes and
+// StreamPastes when BatchOptions.Concurrency is not set.
+const DefaultBatchConcurrency = 4
+
+type BatchOptions struct {
+	// Number of pastes fetched at the same time
+	Concurrency int
+}
+
+// PasteResult is the outcome of fetching a single paste in a batch. Index is
+// the position of ID in the slice passed to GetPastes or StreamPastes.
+type PasteResult struct {
+	Index int
+	ID    string
+	Paste *Paste
+	Err   error
+}
+
+// GetPastes fetches every paste in ids using a pool of workers and returns
+// one result per ID, in the same order as ids. A failure to fetch one paste
+// is reported in its result and does not stop the others.
+//
+// Requests go through the client's rate limiter, if one is configured.
+func (c *Client) GetPastes(ctx context.Context, ids []string, opts BatchOptions, requestOpts ...RequestOption) []PasteResult {
+	results := make([]PasteResult, len(ids))
+	reported := make([]bool, len(ids))
+
+	for result := range c.StreamPastes(ctx, ids, opts, requestOpts...) {
+		results[result.Index] = result
+		reported[result.Index] = true
+	}
+
+	// StreamPastes stops reporting once ctx is done
+	for index, ok := range reported {
+		if !ok {
+			results[index] = PasteResult{Index: index, ID: ids[index], Err: ctx.Err()}
+		}
+	}
+
+	return results
+}
+
+// StreamPastes works like GetPastes but sends each result on the returned
+// channel as soon as it is available, so results arrive in completion order.
+// The channel is closed once every ID has been reported. If ctx is cancelled,
+// the channel is closed early, so a caller can stop reading by cancelling
+// ctx. Results not yet delivered then are dropped.
+func (c *Client) StreamPastes(ctx context.Context, ids []string, opts BatchOptions, requestOpts ...RequestOption) <-chan PasteResult {
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = DefaultBatchConcurrency
+	}
+	if workers > len(ids) {
+		workers = len(ids)
+	}
+
+	jobs := make(chan int)
+	results := make(chan PasteResult)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range jobs {
+				result := PasteResult{Index: index, ID: ids[index]}
+
+				if err := ctx.Err(); err != nil {
+					result.Err = err
+				} else {
+					result.Paste, result.Err = c.GetPaste(ctx, ids[index], requestOpts...)
+				}
+
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+	dispatch:
+		for index := range ids {
+			select {
+			case jobs <- index:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		close(jobs)
+
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
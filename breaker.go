@@ -0,0 +1,285 @@
+package gopastemyst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults for CircuitBreakerOptions
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitErrorRate        = 0.5
+	DefaultCircuitMinRequests      = 20
+	DefaultCircuitWindow           = time.Minute
+	DefaultCircuitOpenTimeout      = 30 * time.Second
+	DefaultCircuitHalfOpenProbes   = 1
+)
+
+// ErrCircuitOpen is returned instead of sending a request while the client's
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitState int
+
+const (
+	// Requests are sent normally
+	CircuitClosed CircuitState = iota
+	// Requests fail with ErrCircuitOpen without being sent
+	CircuitOpen
+	// A few probe requests are sent to see whether the API has recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+type CircuitBreakerOptions struct {
+	// Failures in a row opening the circuit
+	FailureThreshold int
+
+	// Share of failed requests opening the circuit, once at least MinRequests
+	// requests were made within the current Window. Negative disables it.
+	ErrorRate   float64
+	MinRequests int
+	Window      time.Duration
+
+	// How long the circuit stays open before probing the API
+	OpenTimeout time.Duration
+
+	// Probes sent at the same time while half-open. The circuit closes once
+	// that many probes succeed in a row, and opens again on any failure.
+	HalfOpenProbes int
+
+	// Called after every state change, for alerting. It must not block.
+	OnStateChange func(from, to CircuitState)
+}
+
+// CircuitBreaker stops requests to an API that keeps failing, so callers
+// fail fast instead of each waiting out the timeout. Requests failing to get
+// a response, or getting a 5xx one, count as failures. Requests cancelled or
+// timed out by their caller's context, or failing to read their own body,
+// don't count. It can be shared between clients.
+type CircuitBreaker struct {
+	opts CircuitBreakerOptions
+
+	mu          sync.Mutex
+	state       CircuitState
+	generation  uint64
+	openedAt    time.Time
+	windowStart time.Time
+	requests    int
+	failures    int
+	consecutive int
+	probes      int
+	successes   int
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker, filling in defaults for
+// zero options.
+func NewCircuitBreaker(options CircuitBreakerOptions) *CircuitBreaker {
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = DefaultCircuitFailureThreshold
+	}
+	if options.ErrorRate == 0 {
+		options.ErrorRate = DefaultCircuitErrorRate
+	}
+	if options.MinRequests <= 0 {
+		options.MinRequests = DefaultCircuitMinRequests
+	}
+	if options.Window <= 0 {
+		options.Window = DefaultCircuitWindow
+	}
+	if options.OpenTimeout <= 0 {
+		options.OpenTimeout = DefaultCircuitOpenTimeout
+	}
+	if options.HalfOpenProbes <= 0 {
+		options.HalfOpenProbes = DefaultCircuitHalfOpenProbes
+	}
+
+	return &CircuitBreaker{opts: options, windowStart: time.Now()}
+}
+
+// WithCircuitBreaker makes every request made by the client go through
+// breaker, failing with ErrCircuitOpen while it is open.
+func WithCircuitBreaker(breaker *CircuitBreaker) ClientOption {
+	return func(c *Client) {
+		c.breaker = breaker
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	state, changed := b.refresh(time.Now())
+	b.mu.Unlock()
+
+	b.notify(changed)
+
+	return state
+}
+
+// circuitOutcome is what a request tells the breaker about the API.
+type circuitOutcome int
+
+const (
+	circuitSuccess circuitOutcome = iota
+	circuitFailure
+	// The request says nothing about the API, like when it was cancelled
+	circuitIgnored
+)
+
+// allow reports whether a request may be sent, returning the generation its
+// outcome belongs to. Outcomes from an earlier generation are dropped, so
+// requests sent before a state change can't affect the new state.
+func (b *CircuitBreaker) allow() (uint64, error) {
+	b.mu.Lock()
+	state, changed := b.refresh(time.Now())
+
+	var err error
+	switch state {
+	case CircuitOpen:
+		err = ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probes >= b.opts.HalfOpenProbes {
+			err = ErrCircuitOpen
+		} else {
+			b.probes++
+		}
+	}
+	generation := b.generation
+	b.mu.Unlock()
+
+	b.notify(changed)
+
+	return generation, err
+}
+
+// done records the outcome of a request allowed in generation.
+func (b *CircuitBreaker) done(generation uint64, outcome circuitOutcome) {
+	b.mu.Lock()
+	now := time.Now()
+	state, changed := b.refresh(now)
+
+	if generation == b.generation {
+		switch state {
+		case CircuitClosed:
+			changed = append(changed, b.recordClosed(now, outcome)...)
+		case CircuitHalfOpen:
+			b.probes--
+			switch outcome {
+			case circuitFailure:
+				changed = append(changed, b.setState(CircuitOpen, now))
+			case circuitSuccess:
+				b.successes++
+				if b.successes >= b.opts.HalfOpenProbes {
+					changed = append(changed, b.setState(CircuitClosed, now))
+				}
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	b.notify(changed)
+}
+
+func (b *CircuitBreaker) recordClosed(now time.Time, outcome circuitOutcome) []circuitChange {
+	if outcome == circuitIgnored {
+		return nil
+	}
+
+	b.requests++
+	if outcome == circuitSuccess {
+		b.consecutive = 0
+		return nil
+	}
+	b.failures++
+	b.consecutive++
+
+	tripped := b.consecutive >= b.opts.FailureThreshold ||
+		(b.opts.ErrorRate > 0 && b.requests >= b.opts.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.opts.ErrorRate)
+	if !tripped {
+		return nil
+	}
+
+	return []circuitChange{b.setState(CircuitOpen, now)}
+}
+
+type circuitChange struct {
+	from, to CircuitState
+}
+
+// refresh moves the breaker along with time: from open to half-open once the
+// open timeout has passed, and to a new error rate window when the current
+// one is over.
+func (b *CircuitBreaker) refresh(now time.Time) (CircuitState, []circuitChange) {
+	var changed []circuitChange
+
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) >= b.opts.OpenTimeout {
+			changed = append(changed, b.setState(CircuitHalfOpen, now))
+		}
+	case CircuitClosed:
+		if now.Sub(b.windowStart) >= b.opts.Window {
+			b.windowStart = now
+			b.requests, b.failures = 0, 0
+		}
+	}
+
+	return b.state, changed
+}
+
+func (b *CircuitBreaker) setState(state CircuitState, now time.Time) circuitChange {
+	change := circuitChange{from: b.state, to: state}
+
+	b.state = state
+	b.generation++
+	b.windowStart = now
+	b.requests, b.failures, b.consecutive = 0, 0, 0
+	b.probes, b.successes = 0, 0
+	if state == CircuitOpen {
+		b.openedAt = now
+	}
+
+	return change
+}
+
+// notify calls OnStateChange for changes, outside of the breaker's lock.
+func (b *CircuitBreaker) notify(changed []circuitChange) {
+	if b.opts.OnStateChange == nil {
+		return
+	}
+
+	for _, change := range changed {
+		b.opts.OnStateChange(change.from, change.to)
+	}
+}
+
+// circuitResult classifies the result of sending a request.
+func circuitResult(res *http.Response, err error) circuitOutcome {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return circuitIgnored
+	case err != nil:
+		return circuitFailure
+	case res.StatusCode >= http.StatusInternalServerError:
+		return circuitFailure
+	default:
+		return circuitSuccess
+	}
+}
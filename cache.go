@@ -0,0 +1,344 @@
+package gopastemyst
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sammie156/go-pastemyst/internal/fileutil"
+)
+
+// DefaultCacheTTL is used when WithCache is given a zero TTL and the API
+// response carries no Cache-Control max-age.
+const DefaultCacheTTL = time.Minute
+
+// CacheEntry is a stored API response body along with the validators needed
+// to revalidate it with a conditional request.
+type CacheEntry struct {
+	Body         []byte    `json:"body"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// Cache is a storage backend for cached responses. Implementations must be
+// safe for concurrent use.
+type Cache interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+// WithCache enables response caching for GetPaste, GetPasteStats and
+// GetPasteLanguageStats. Entries live for the max-age sent by the API, or for
+// ttl when none is sent. Stale entries are revalidated with If-None-Match and
+// If-Modified-Since before being discarded.
+func WithCache(cache Cache, ttl time.Duration) ClientOption {
+	return func(c *Client) {
+		if ttl <= 0 {
+			ttl = DefaultCacheTTL
+		}
+		c.cache = cache
+		c.cacheTTL = ttl
+	}
+}
+
+// getCached performs a GET request for url, serving it from the cache when a
+// fresh entry exists and storing the result when the response allows it.
+func (c *Client) getCached(ctx context.Context, url string, opts []RequestOption) ([]byte, error) {
+	cfg := newRequestConfig(opts)
+	if c.cache == nil || len(cfg.header) > 0 {
+		return c.get(ctx, url, opts)
+	}
+
+	token, err := c.requestToken(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	key := cacheKey(url, token)
+
+	if cached, ok := c.cache.Get(key); ok && time.Now().Before(cached.ExpiresAt) {
+		return cached.Body, nil
+	}
+
+	return c.flights.do(ctx, flightKey(true, url, token, cfg), func(ctx context.Context) ([]byte, error) {
+		return c.fetch(ctx, url, key, opts)
+	})
+}
+
+// fetch performs a GET request for url and returns the response body. With a
+// cache key set, a stale cache entry is revalidated and the response stored.
+func (c *Client) fetch(ctx context.Context, url string, key string, opts []RequestOption) ([]byte, error) {
+	useCache := key != ""
+
+	var entry *CacheEntry
+	if useCache {
+		if cached, ok := c.cache.Get(key); ok {
+			if time.Now().Before(cached.ExpiresAt) {
+				return cached.Body, nil
+			}
+			entry = cached
+		}
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, url, nil, false, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	res, err := c.do(req, opts)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified && entry != nil {
+		if ttl, store := c.cacheLifetime(res.Header); store {
+			entry.ExpiresAt = time.Now().Add(ttl)
+			c.cache.Set(key, entry)
+		}
+		return entry.Body, nil
+	}
+
+	if err := checkResponse(res, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if useCache {
+		if ttl, store := c.cacheLifetime(res.Header); store {
+			c.cache.Set(key, &CacheEntry{
+				Body:         body,
+				ETag:         res.Header.Get("ETag"),
+				LastModified: res.Header.Get("Last-Modified"),
+				ExpiresAt:    time.Now().Add(ttl),
+			})
+		} else {
+			c.cache.Delete(key)
+		}
+	}
+
+	return body, nil
+}
+
+// cacheLifetime reads the Cache-Control header of a response and reports how
+// long it may be served from cache, and whether it may be stored at all.
+func (c *Client) cacheLifetime(header http.Header) (time.Duration, bool) {
+	ttl := c.cacheTTL
+
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+
+		switch strings.ToLower(name) {
+		case "no-store":
+			return 0, false
+		case "no-cache":
+			// Keep the entry for revalidation but never serve it unchecked
+			ttl = 0
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && ttl != 0 {
+				ttl = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	return ttl, true
+}
+
+// invalidatePaste drops the cached responses belonging to pasteID, both the
+// anonymous ones and those of the client's token. It is called after any
+// request that modifies the paste.
+func (c *Client) invalidatePaste(ctx context.Context, pasteID string) {
+	if c.cache == nil {
+		return
+	}
+
+	tokens := []string{""}
+	if token, err := c.tokens.Token(ctx); err == nil && token != "" {
+		tokens = append(tokens, token)
+	}
+
+	for _, url := range c.pasteCacheKeys(pasteID) {
+		for _, token := range tokens {
+			c.cache.Delete(cacheKey(url, token))
+		}
+	}
+}
+
+// cacheKey scopes a cached response to the token it was fetched with, so
+// private pastes are never served to anonymous calls or to other tokens
+// sharing the cache. Anonymous responses are keyed by their URL alone.
+func cacheKey(url string, token string) string {
+	if token == "" {
+		return url
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	return url + " " + hex.EncodeToString(sum[:8])
+}
+
+func (c *Client) pasteCacheKeys(pasteID string) []string {
+	return []string{
+		fmt.Sprintf("%s/pastes/%s", c.baseURL, pasteID),
+		fmt.Sprintf("%s/pastes/%s/stats", c.baseURL, pasteID),
+		fmt.Sprintf("%s/pastes/%s/langs", c.baseURL, pasteID),
+	}
+}
+
+// ----- IN-MEMORY LRU CACHE -----
+
+// LRUCache is an in-memory Cache that evicts the least recently used entry
+// once it holds more than its configured number of entries.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewLRUCache creates an LRUCache holding at most capacity entries.
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity <= 0 {
+		capacity = 1
+	}
+
+	return &LRUCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (l *LRUCache) Get(key string) (*CacheEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+
+	// Hand out a copy so callers can't mutate the stored entry
+	entry := *elem.Value.(*lruItem).entry
+	return &entry, true
+}
+
+func (l *LRUCache) Set(key string, entry *CacheEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stored := *entry
+	if elem, ok := l.items[key]; ok {
+		elem.Value.(*lruItem).entry = &stored
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	l.items[key] = l.order.PushFront(&lruItem{key: key, entry: &stored})
+
+	for l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+func (l *LRUCache) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		l.order.Remove(elem)
+		delete(l.items, key)
+	}
+}
+
+// ----- ON-DISK CACHE -----
+
+// DiskCache is a Cache that keeps one JSON file per entry inside a directory,
+// so cached responses survive process restarts. Failures to read or write the
+// directory are treated as cache misses.
+type DiskCache struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewDiskCache creates a DiskCache rooted at dir, creating it if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create cache directory: %w", err)
+	}
+
+	return &DiskCache{dir: dir}, nil
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (d *DiskCache) Get(key string) (*CacheEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+func (d *DiskCache) Set(key string, entry *CacheEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	fileutil.WriteFileAtomic(d.dir, d.path(key), data)
+}
+
+func (d *DiskCache) Delete(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	os.Remove(d.path(key))
+}
@@ -0,0 +1,323 @@
+package gopastemyst
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ChunkIndexFormat identifies the index pastes written by CreateChunkedPaste.
+const ChunkIndexFormat = "go-pastemyst/chunked"
+
+// Title of the pasty holding the chunk index inside an index paste
+const chunkIndexPastyTitle = "chunk-index.json"
+
+type ChunkLimits struct {
+	// Largest pasty content, in bytes. Defaults to MaxPasteSize.
+	MaxPastySize int
+	// Largest content of a whole paste, in bytes. Defaults to the client's
+	// limit, see WithMaxPasteSize.
+	MaxPasteSize int
+}
+
+func (l ChunkLimits) withDefaults(maxPasteSize int64) ChunkLimits {
+	if l.MaxPasteSize <= 0 {
+		l.MaxPasteSize = int(maxPasteSize)
+	}
+	if l.MaxPastySize <= 0 || l.MaxPastySize > l.MaxPasteSize {
+		l.MaxPastySize = l.MaxPasteSize
+	}
+
+	return l
+}
+
+// ChunkIndex is stored in the index paste of content spread over several
+// pastes, and lists where every chunk of every original pasty lives.
+type ChunkIndex struct {
+	Format  string              `json:"format"`
+	Version int                 `json:"version"`
+	Title   string              `json:"title"`
+	Pasties []ChunkedPastyIndex `json:"pasties"`
+}
+
+type ChunkedPastyIndex struct {
+	Title    string     `json:"title"`
+	Language string     `json:"language"`
+	Size     int        `json:"size"`
+	Chunks   []ChunkRef `json:"chunks"`
+}
+
+type ChunkRef struct {
+	PasteID string `json:"pasteId"`
+	PastyID string `json:"pastyId"`
+}
+
+// ChunkedPaste is the result of CreateChunkedPaste. Index is nil when the
+// content fit in a single paste, which is then the only entry of Parts.
+type ChunkedPaste struct {
+	Index *Paste
+	Parts []*Paste
+}
+
+// SplitPasty splits a pasty into numbered pasties of at most maxSize bytes
+// each, cutting on line boundaries. Lines longer than maxSize are cut
+// between characters. Concatenating the content of the returned pasties in
+// order gives back the original content.
+func SplitPasty(pasty CreatePastyOptions, maxSize int) []CreatePastyOptions {
+	chunks := splitContent(pasty.Content, maxSize)
+	if len(chunks) == 1 {
+		return []CreatePastyOptions{pasty}
+	}
+
+	pasties := make([]CreatePastyOptions, 0, len(chunks))
+	for i, chunk := range chunks {
+		pasties = append(pasties, CreatePastyOptions{
+			Title:    fmt.Sprintf("%s (%d/%d)", pasty.Title, i+1, len(chunks)),
+			Content:  chunk,
+			Language: pasty.Language,
+		})
+	}
+
+	return pasties
+}
+
+func splitContent(content string, maxSize int) []string {
+	if maxSize <= 0 || len(content) <= maxSize {
+		return []string{content}
+	}
+
+	var chunks []string
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if current.Len()+len(line) > maxSize {
+			flush()
+		}
+
+		for len(line) > maxSize {
+			cut := maxSize
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = maxSize
+			}
+
+			chunks = append(chunks, line[:cut])
+			line = line[cut:]
+		}
+
+		current.WriteString(line)
+	}
+	flush()
+
+	return chunks
+}
+
+// CreateChunkedPaste creates a paste like CreatePaste, splitting pasties that
+// are over the limits. When everything fits in one paste only that paste is
+// created. Otherwise the chunks are spread over as many part pastes as
+// needed, and an index paste titled like the original links them together.
+// Pass the index paste ID to ReassemblePaste to get the original back.
+//
+// If creating a part fails, the parts created so far are returned alongside
+// the error.
+func (c *Client) CreateChunkedPaste(ctx context.Context, options CreatePasteOptions, limits ChunkLimits, opts ...RequestOption) (*ChunkedPaste, error) {
+	if len(options.Pasties) == 0 {
+		return nil, fmt.Errorf("at least one pasty should be present")
+	}
+	limits = limits.withDefaults(c.maxSize())
+
+	type chunk struct {
+		pasty   int
+		options CreatePastyOptions
+	}
+
+	var chunks []chunk
+	total := 0
+	for i, pasty := range options.Pasties {
+		for _, part := range SplitPasty(pasty, limits.MaxPastySize) {
+			chunks = append(chunks, chunk{pasty: i, options: part})
+			total += len(part.Content)
+		}
+	}
+
+	if total <= limits.MaxPasteSize {
+		single := options
+		single.Pasties = make([]CreatePastyOptions, 0, len(chunks))
+		for _, chunk := range chunks {
+			single.Pasties = append(single.Pasties, chunk.options)
+		}
+
+		paste, err := c.CreatePaste(ctx, single, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &ChunkedPaste{Parts: []*Paste{paste}}, nil
+	}
+
+	// Pack chunks into as few pastes as possible, keeping their order
+	var groups [][]chunk
+	size := 0
+	for _, chunk := range chunks {
+		if len(groups) == 0 || size+len(chunk.options.Content) > limits.MaxPasteSize {
+			groups = append(groups, nil)
+			size = 0
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], chunk)
+		size += len(chunk.options.Content)
+	}
+
+	index := ChunkIndex{
+		Format:  ChunkIndexFormat,
+		Version: 1,
+		Title:   options.Title,
+	}
+	for _, pasty := range options.Pasties {
+		index.Pasties = append(index.Pasties, ChunkedPastyIndex{
+			Title:    pasty.Title,
+			Language: pasty.Language,
+			Size:     len(pasty.Content),
+		})
+	}
+
+	result := &ChunkedPaste{}
+	for i, group := range groups {
+		part := options
+		part.Title = fmt.Sprintf("%s (part %d/%d)", options.Title, i+1, len(groups))
+		part.Pasties = make([]CreatePastyOptions, 0, len(group))
+		for _, chunk := range group {
+			part.Pasties = append(part.Pasties, chunk.options)
+		}
+
+		paste, err := c.CreatePaste(ctx, part, appendOptions(opts, withIdempotencySuffix(fmt.Sprintf("part-%d", i+1)))...)
+		if err != nil {
+			return result, fmt.Errorf("could not create part %d of %d: %w", i+1, len(groups), err)
+		}
+		if len(paste.Pasties) != len(group) {
+			return result, fmt.Errorf("part %d was created with %d pasties instead of %d", i+1, len(paste.Pasties), len(group))
+		}
+		result.Parts = append(result.Parts, paste)
+
+		for j, chunk := range group {
+			entry := &index.Pasties[chunk.pasty]
+			entry.Chunks = append(entry.Chunks, ChunkRef{PasteID: paste.ID, PastyID: paste.Pasties[j].ID})
+		}
+	}
+
+	indexJSON, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return result, fmt.Errorf("could not encode chunk index: %w", err)
+	}
+
+	indexOptions := options
+	indexOptions.Pasties = []CreatePastyOptions{{
+		Title:    chunkIndexPastyTitle,
+		Content:  string(indexJSON),
+		Language: "JSON",
+	}}
+
+	result.Index, err = c.CreatePaste(ctx, indexOptions, appendOptions(opts, withIdempotencySuffix("index"))...)
+	if err != nil {
+		return result, fmt.Errorf("could not create index paste: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetChunkIndex loads the chunk index stored in an index paste created by
+// CreateChunkedPaste.
+func (c *Client) GetChunkIndex(ctx context.Context, indexPasteID string, opts ...RequestOption) (*ChunkIndex, error) {
+	paste, err := c.GetPaste(ctx, indexPasteID, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pasty := range paste.Pasties {
+		if pasty.Title != chunkIndexPastyTitle {
+			continue
+		}
+
+		var index ChunkIndex
+		if err := json.Unmarshal([]byte(pasty.Content), &index); err != nil {
+			return nil, fmt.Errorf("could not decode chunk index: %w", err)
+		}
+		if index.Format != ChunkIndexFormat {
+			return nil, fmt.Errorf("paste %s is not a chunk index", indexPasteID)
+		}
+
+		return &index, nil
+	}
+
+	return nil, fmt.Errorf("paste %s is not a chunk index", indexPasteID)
+}
+
+// ReassemblePasty writes the original content of the pasty at position
+// pasty in the index to w, fetching the part pastes one at a time.
+func (c *Client) ReassemblePasty(ctx context.Context, index *ChunkIndex, pasty int, w io.Writer, opts ...RequestOption) error {
+	if pasty < 0 || pasty >= len(index.Pasties) {
+		return fmt.Errorf("chunk index has no pasty %d", pasty)
+	}
+
+	for _, ref := range index.Pasties[pasty].Chunks {
+		part, err := c.GetPaste(ctx, ref.PasteID, opts...)
+		if err != nil {
+			return fmt.Errorf("could not fetch part %s: %w", ref.PasteID, err)
+		}
+
+		found := false
+		for _, chunk := range part.Pasties {
+			if chunk.ID != ref.PastyID {
+				continue
+			}
+			if _, err := io.WriteString(w, chunk.Content); err != nil {
+				return err
+			}
+			found = true
+			break
+		}
+
+		if !found {
+			return fmt.Errorf("part %s is missing pasty %s", ref.PasteID, ref.PastyID)
+		}
+	}
+
+	return nil
+}
+
+// ReassemblePaste rebuilds the paste split by CreateChunkedPaste from its
+// index paste, returned as options that would create it again.
+func (c *Client) ReassemblePaste(ctx context.Context, indexPasteID string, opts ...RequestOption) (*CreatePasteOptions, error) {
+	index, err := c.GetChunkIndex(ctx, indexPasteID, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &CreatePasteOptions{Title: index.Title}
+	for i, pasty := range index.Pasties {
+		var content strings.Builder
+		content.Grow(pasty.Size)
+
+		if err := c.ReassemblePasty(ctx, index, i, &content, opts...); err != nil {
+			return nil, err
+		}
+
+		options.Pasties = append(options.Pasties, CreatePastyOptions{
+			Title:    pasty.Title,
+			Content:  content.String(),
+			Language: pasty.Language,
+		})
+	}
+
+	return options, nil
+}
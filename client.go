@@ -1,7 +1,12 @@
 package gopastemyst
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -10,24 +15,113 @@ const BaseURL = "https://beta.myst.rs/api/v3"
 
 type Client struct {
 	baseURL    string
-	apiToken   string // Storing the user's API Token
+	tokens     TokenSource // Provides the user's API token, see WithTokenSource
 	httpClient *http.Client
+
+	// Optional response cache, see WithCache
+	cache    Cache
+	cacheTTL time.Duration
+
+	// In-flight GET requests shared between concurrent callers
+	flights flightGroup
+
+	// Optional limiter every request waits on, see WithRateLimiter
+	limiter RateLimiter
+
+	// Optional pre-submit content check, see WithSecretScanner
+	scanner *SecretScanner
+
+	// Optional journal of idempotency keys, see WithIdempotency
+	idempotency *IdempotencyOptions
+	// Calls made with the same idempotency key, run one at a time
+	idempotencyLocks keyLocks
+
+	// Optional store of created pastes by content, see WithDedup
+	dedup DedupStore
+
+	// Optional breaker failing requests fast while the API is down, see
+	// WithCircuitBreaker
+	breaker *CircuitBreaker
+
+	// Content size limit used instead of DefaultMaxPasteSize, see
+	// WithMaxPasteSize
+	maxPasteSize int64
 }
 
-func NewClient(apiToken string) *Client {
-	return &Client{
-		baseURL:  BaseURL,
-		apiToken: apiToken,
+// ClientOption configures optional behaviour of a Client created with NewClient.
+type ClientOption func(*Client)
+
+// NewClient creates a client sending apiToken with every request, or none when
+// it is empty. Pass WithTokenSource to get the token from elsewhere.
+func NewClient(apiToken string, opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL: BaseURL,
+		tokens:  StaticToken(apiToken),
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithBaseURL points the client at another PasteMyst v3 API, such as a
+// self-hosted instance or the server package, instead of BaseURL.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
 }
 
 type APIError struct {
 	StatusMessage string `json:"statusMessage"`
+
+	// HTTP status of the response the error was decoded from
+	StatusCode int `json:"-"`
 }
 
 func (e APIError) Error() string {
 	return e.StatusMessage
 }
+
+// Matched by errors.Is against errors returned for API responses
+var (
+	// The paste or user doesn't exist. The API gives the same answer for a
+	// private paste the token doesn't own, so it can't be told apart from a
+	// deleted one.
+	ErrNotFound = errors.New("not found")
+	// The action needs a token, or the owner's token
+	ErrAccessDenied = errors.New("access denied")
+)
+
+func (e APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrAccessDenied:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	default:
+		return false
+	}
+}
+
+// checkResponse returns an error wrapping an APIError unless res has one of
+// the expected statuses.
+func checkResponse(res *http.Response, expected ...int) error {
+	if slices.Contains(expected, res.StatusCode) {
+		return nil
+	}
+
+	apiError := APIError{StatusCode: res.StatusCode}
+
+	if err := json.NewDecoder(res.Body).Decode(&apiError); err == nil && apiError.StatusMessage != "" {
+		return fmt.Errorf("API Error (%s): %w", res.Status, apiError)
+	}
+
+	apiError.StatusMessage = res.Status
+	return fmt.Errorf("API returned non-%d status: %w", expected[0], apiError)
+}
@@ -0,0 +1,125 @@
+// Command pastemyst-server runs a self-hosted PasteMyst v3 API.
+//
+//	pastemyst-server -addr :5000 -data ./pastes -users ./users.txt
+//
+// Clients use http://host:5000/api/v3 as their base URL. Without -data,
+// everything is kept in memory.
+//
+// Users to create or update are read from the -users file, one name:token per
+// line, with blank lines and lines starting with # ignored. They can also be
+// given in the PASTEMYST_USERS environment variable, as name:token entries
+// separated by whitespace. Tokens are never passed as arguments, which other
+// users of the machine could read.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Sammie156/go-pastemyst/server"
+)
+
+func main() {
+	addr := flag.String("addr", ":5000", "address to listen on")
+	dataDir := flag.String("data", "", "directory to store pastes in, in memory if empty")
+	reapInterval := flag.Duration("reap-interval", server.DefaultReapInterval, "how often expired pastes are deleted")
+	usersFile := flag.String("users", "", "file listing the `name:token` of users to create or update, one per line")
+
+	flag.Parse()
+
+	users, err := readUsers(*usersFile, os.Getenv("PASTEMYST_USERS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := run(*addr, *dataDir, *reapInterval, users); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readUsers returns the name:token entries of the users file at path, if any,
+// followed by those in env.
+func readUsers(path string, env string) ([]string, error) {
+	var entries []string
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read users file: %w", err)
+		}
+
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				entries = append(entries, line)
+			}
+		}
+	}
+
+	entries = append(entries, strings.Fields(env)...)
+
+	// Errors leave the entry out, since it may hold a token
+	for i, entry := range entries {
+		if name, token, ok := strings.Cut(entry, ":"); !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("user entry %d is invalid, expected name:token", i+1)
+		}
+	}
+
+	return entries, nil
+}
+
+func run(addr string, dataDir string, reapInterval time.Duration, users []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var store server.Store = server.NewMemoryStore()
+	if dataDir != "" {
+		fileStore, err := server.NewFileStore(dataDir)
+		if err != nil {
+			return err
+		}
+		store = fileStore
+	}
+
+	srv := server.New(store, server.Options{ReapInterval: reapInterval})
+
+	for _, user := range users {
+		name, token, _ := strings.Cut(user, ":")
+		if _, err := srv.AddUser(ctx, name, token); err != nil {
+			return fmt.Errorf("could not add user %s: %w", name, err)
+		}
+	}
+
+	go srv.RunReaper(ctx)
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("pastemyst-server listening on %s%s", addr, server.APIPrefix)
+
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
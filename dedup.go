@@ -0,0 +1,267 @@
+package gopastemyst
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sammie156/go-pastemyst/internal/fileutil"
+)
+
+// DedupEntry is a paste created by the client, stored under the hash of the
+// options it was created from.
+type DedupEntry struct {
+	PasteID   string     `json:"pasteID"`
+	DeletesAt *time.Time `json:"deletesAt,omitempty"`
+}
+
+// expired reports whether a paste deleted at deletesAt is gone.
+func expired(deletesAt *time.Time) bool {
+	return deletesAt != nil && !time.Now().Before(*deletesAt)
+}
+
+// DedupStore remembers the pastes created by the client by content hash.
+// Implementations must be safe for concurrent use.
+type DedupStore interface {
+	Get(hash string) (*DedupEntry, bool)
+	Set(hash string, entry *DedupEntry)
+	Delete(hash string)
+}
+
+// WithDedup makes CreatePaste return the paste it created earlier from the
+// same content, as long as it hasn't expired, instead of creating it again.
+// Options are compared after normalizing line endings, tags, languages and
+// the default expiry, and pastes are only shared between calls made with
+// the same token. Pass WithoutDedup to create a new paste regardless. A nil
+// store keeps entries in memory.
+func WithDedup(store DedupStore) ClientOption {
+	return func(c *Client) {
+		if store == nil {
+			store = NewMemoryDedupStore()
+		}
+		c.dedup = store
+	}
+}
+
+// WithoutDedup makes CreatePaste create a new paste even when the client's
+// dedup store has one with the same content.
+func WithoutDedup() RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.noDedup = true
+	}
+}
+
+// createDeduped returns the unexpired paste stored for options, or runs
+// create and stores the paste it returns.
+func (c *Client) createDeduped(ctx context.Context, options CreatePasteOptions, opts []RequestOption, create func() (*Paste, error)) (*Paste, error) {
+	token, err := c.requestToken(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	hash := dedupHash(options, token)
+
+	if entry, ok := c.dedup.Get(hash); ok {
+		if paste, err := c.dedupedPaste(ctx, entry, options, opts); err != nil {
+			return nil, err
+		} else if paste != nil {
+			return paste, nil
+		}
+		c.dedup.Delete(hash)
+	}
+
+	paste, err := create()
+	if err != nil {
+		return nil, err
+	}
+
+	c.dedup.Set(hash, &DedupEntry{PasteID: paste.ID, DeletesAt: paste.DeletesAt})
+
+	return paste, nil
+}
+
+// dedupedPaste fetches the paste of entry, returning nil when it has expired,
+// was deleted or no longer has the content of options.
+func (c *Client) dedupedPaste(ctx context.Context, entry *DedupEntry, options CreatePasteOptions, opts []RequestOption) (*Paste, error) {
+	if expired(entry.DeletesAt) {
+		return nil, nil
+	}
+
+	paste, err := c.fetchPaste(ctx, entry.PasteID, opts)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The expiry may have changed since the entry was stored
+	if expired(paste.DeletesAt) {
+		return nil, nil
+	}
+
+	// So may the content, if the paste was edited
+	if !sameContent(paste, options) {
+		return nil, nil
+	}
+
+	return paste, nil
+}
+
+// sameContent reports whether paste has the pasty content of options, with
+// line endings normalized like dedupHash does.
+func sameContent(paste *Paste, options CreatePasteOptions) bool {
+	if len(paste.Pasties) != len(options.Pasties) {
+		return false
+	}
+
+	for i, pasty := range paste.Pasties {
+		if strings.ReplaceAll(pasty.Content, "\r\n", "\n") != strings.ReplaceAll(options.Pasties[i].Content, "\r\n", "\n") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dedupHash hashes the normalized form of options along with the token the
+// paste is created with.
+func dedupHash(options CreatePasteOptions, token string) string {
+	normalized := options
+	normalized.Title = strings.TrimSpace(options.Title)
+
+	if normalized.ExpiresIn == "" {
+		normalized.ExpiresIn = "never"
+	}
+
+	normalized.Tags = nil
+	for _, tag := range options.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(normalized.Tags, tag) {
+			normalized.Tags = append(normalized.Tags, tag)
+		}
+	}
+	slices.Sort(normalized.Tags)
+
+	normalized.Pasties = make([]CreatePastyOptions, len(options.Pasties))
+	for i, pasty := range options.Pasties {
+		normalized.Pasties[i] = CreatePastyOptions{
+			Title:    strings.TrimSpace(pasty.Title),
+			Language: strings.ToLower(strings.TrimSpace(pasty.Language)),
+			Content:  strings.ReplaceAll(pasty.Content, "\r\n", "\n"),
+		}
+	}
+
+	h := sha256.New()
+	writeHashField(h, token)
+	json.NewEncoder(h).Encode(normalized)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ----- IN-MEMORY DEDUP STORE -----
+
+// MemoryDedupStore is a DedupStore kept in memory.
+type MemoryDedupStore struct {
+	mu      sync.Mutex
+	entries map[string]DedupEntry
+}
+
+// NewMemoryDedupStore creates an empty MemoryDedupStore.
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{entries: make(map[string]DedupEntry)}
+}
+
+func (m *MemoryDedupStore) Get(hash string) (*DedupEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[hash]
+	if !ok {
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+func (m *MemoryDedupStore) Set(hash string, entry *DedupEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[hash] = *entry
+}
+
+func (m *MemoryDedupStore) Delete(hash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, hash)
+}
+
+// ----- ON-DISK DEDUP STORE -----
+
+// DiskDedupStore is a DedupStore keeping one JSON file per entry inside a
+// directory, so CI runs sharing the directory reuse each other's pastes.
+// Failures to read or write the directory are treated as misses.
+type DiskDedupStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewDiskDedupStore creates a DiskDedupStore rooted at dir, creating it if
+// needed.
+func NewDiskDedupStore(dir string) (*DiskDedupStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create dedup directory: %w", err)
+	}
+
+	return &DiskDedupStore{dir: dir}, nil
+}
+
+func (d *DiskDedupStore) path(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (d *DiskDedupStore) Get(hash string) (*DedupEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data, err := os.ReadFile(d.path(hash))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry DedupEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+func (d *DiskDedupStore) Set(hash string, entry *DedupEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	fileutil.WriteFileAtomic(d.dir, d.path(hash), data)
+}
+
+func (d *DiskDedupStore) Delete(hash string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	os.Remove(d.path(hash))
+}
@@ -0,0 +1,530 @@
+package gopastemyst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SyncStateFile is the file DirSync keeps its state in, inside the synced
+// directory. It is skipped when scanning the directory for pasties.
+const SyncStateFile = ".pastemyst-sync.json"
+
+// DirSync maps a local directory to a paste, with one file per pasty. Files
+// are matched to pasties by pasty ID, so renaming a pasty on the website
+// keeps it in the same local file.
+type DirSync struct {
+	client  *Client
+	pasteID string
+	dir     string
+}
+
+// syncState is what DirSync remembers about the last successful sync. Base
+// holds the content of each file as it was on the paste at that point, and
+// is what both sides are compared against to detect changes.
+type syncState struct {
+	PasteID  string                `json:"pasteId"`
+	EditedAt *time.Time            `json:"editedAt"`
+	SyncedAt time.Time             `json:"syncedAt"`
+	Files    map[string]syncedFile `json:"files"`
+}
+
+type syncedFile struct {
+	PastyID  string `json:"pastyId"`
+	Language string `json:"language"`
+	Base     string `json:"base"`
+}
+
+type SyncOptions struct {
+	// Resolve conflicts in favour of the side being synced to, instead of
+	// returning a SyncConflictError. Push keeps local files, Pull keeps the
+	// remote pasties.
+	Force bool
+}
+
+// SyncResult lists the files touched by a Push or Pull.
+type SyncResult struct {
+	Added   []string
+	Updated []string
+	Removed []string
+}
+
+// SyncConflict is a file that was changed both locally and on the paste since
+// the last sync. An empty Local or Remote with the matching Exists flag unset
+// means that side deleted the file.
+type SyncConflict struct {
+	File    string
+	PastyID string
+
+	Base         string
+	Local        string
+	LocalExists  bool
+	Remote       string
+	RemoteExists bool
+}
+
+// SyncConflictError is returned by Push and Pull when local and remote changes
+// diverge. Nothing is written when it is returned. RemoteRevisions holds the
+// history IDs of the edits made to the paste since the last sync.
+type SyncConflictError struct {
+	Conflicts       []SyncConflict
+	RemoteRevisions []string
+	Remote          *Paste
+}
+
+func (e *SyncConflictError) Error() string {
+	files := make([]string, 0, len(e.Conflicts))
+	for _, conflict := range e.Conflicts {
+		files = append(files, conflict.File)
+	}
+
+	return fmt.Sprintf("sync conflict in %s", strings.Join(files, ", "))
+}
+
+// NewDirSync creates a DirSync between dir and the paste pasteID. The first
+// Pull populates the directory.
+func (c *Client) NewDirSync(pasteID string, dir string) *DirSync {
+	return &DirSync{client: c, pasteID: pasteID, dir: dir}
+}
+
+// Pull writes the remote state of the paste to the directory. Files changed
+// locally since the last sync are kept unless the pasty changed remotely as
+// well, in which case a SyncConflictError is returned.
+func (s *DirSync) Pull(ctx context.Context, options SyncOptions, opts ...RequestOption) (*SyncResult, error) {
+	state, err := s.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := s.readFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := s.client.fetchPaste(ctx, s.pasteID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	byPasty := make(map[string]string)
+	for file, synced := range state.Files {
+		byPasty[synced.PastyID] = file
+	}
+
+	result := &SyncResult{}
+	writes := make(map[string]string)
+	var conflicts []SyncConflict
+	newState := &syncState{PasteID: s.pasteID, EditedAt: remote.EditedAt, Files: make(map[string]syncedFile)}
+
+	for _, pasty := range remote.Pasties {
+		file, tracked := byPasty[pasty.ID]
+		if !tracked {
+			file = s.fileNameFor(pasty, state, local, newState)
+		}
+		newState.Files[file] = syncedFile{PastyID: pasty.ID, Language: pasty.Language, Base: pasty.Content}
+
+		content, exists := local[file]
+		base := state.Files[file].Base
+		if !tracked {
+			// A file already sitting where a new pasty would go counts as a
+			// local change against an empty base
+			base = ""
+		}
+
+		localChanged := !tracked && exists || tracked && (!exists || content != base)
+		remoteChanged := !tracked || pasty.Content != base
+
+		switch {
+		case !remoteChanged:
+			// Nothing new remotely, local edits are kept for the next push
+			continue
+		case localChanged && exists && content == pasty.Content:
+			continue
+		case localChanged && !options.Force:
+			conflicts = append(conflicts, SyncConflict{
+				File:         file,
+				PastyID:      pasty.ID,
+				Base:         base,
+				Local:        content,
+				LocalExists:  exists,
+				Remote:       pasty.Content,
+				RemoteExists: true,
+			})
+			continue
+		}
+
+		writes[file] = pasty.Content
+		if exists {
+			result.Updated = append(result.Updated, file)
+		} else {
+			result.Added = append(result.Added, file)
+		}
+	}
+
+	// Pasties removed remotely. Unchanged files are deleted, edited ones are
+	// kept and become new pasties on the next push.
+	var removals []string
+	for file, synced := range state.Files {
+		if _, ok := newState.Files[file]; ok {
+			continue
+		}
+
+		if content, exists := local[file]; exists && (content == synced.Base || options.Force) {
+			removals = append(removals, file)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, s.conflictError(ctx, conflicts, state, remote, opts)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create sync directory: %w", err)
+	}
+
+	for file, content := range writes {
+		if err := os.WriteFile(filepath.Join(s.dir, file), []byte(content), 0o644); err != nil {
+			return nil, fmt.Errorf("could not write %s: %w", file, err)
+		}
+	}
+
+	for _, file := range removals {
+		if err := os.Remove(filepath.Join(s.dir, file)); err != nil {
+			return nil, fmt.Errorf("could not remove %s: %w", file, err)
+		}
+		result.Removed = append(result.Removed, file)
+	}
+
+	if err := s.saveState(newState); err != nil {
+		return nil, err
+	}
+
+	result.sort()
+	return result, nil
+}
+
+// Push sends local changes to the paste with EditPasteIfUnchanged. Pasties
+// keep their IDs, new files become new pasties and deleted files remove their
+// pasty. Remote edits made since the last sync to pasties that weren't changed
+// locally are preserved, and files whose pasty was deleted remotely are
+// deleted too when they weren't changed locally. When the same pasty changed
+// on both sides a SyncConflictError is returned and nothing is sent. When the
+// paste is edited while pushing, a *ConflictError is returned.
+func (s *DirSync) Push(ctx context.Context, options SyncOptions, opts ...RequestOption) (*SyncResult, error) {
+	state, err := s.loadState()
+	if err != nil {
+		return nil, err
+	}
+	if state.SyncedAt.IsZero() {
+		return nil, fmt.Errorf("directory %s was never pulled, pull it before pushing", s.dir)
+	}
+
+	local, err := s.readFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := s.client.fetchPaste(ctx, s.pasteID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	remotePasties := make(map[string]Pasty)
+	for _, pasty := range remote.Pasties {
+		remotePasties[pasty.ID] = pasty
+	}
+	remoteUnchanged := sameTime(state.EditedAt, remote.EditedAt)
+
+	result := &SyncResult{}
+	var conflicts []SyncConflict
+
+	pastyFile := make(map[string]string)
+	for file, synced := range state.Files {
+		pastyFile[synced.PastyID] = file
+	}
+
+	// Pasties are sent in the order the paste currently has them. fileOrder
+	// holds the local file of each one, or "" for pasties with no file.
+	var pasties []EditPastyOptions
+	var fileOrder []string
+
+	for _, pasty := range remote.Pasties {
+		file, ok := pastyFile[pasty.ID]
+		if !ok {
+			// Added remotely since the last sync, keep it as is
+			pasties = append(pasties, EditPastyOptions{ID: pasty.ID, Title: pasty.Title, Content: pasty.Content, Language: pasty.Language})
+			fileOrder = append(fileOrder, "")
+			continue
+		}
+
+		base := state.Files[file].Base
+		content, exists := local[file]
+		localChanged := !exists || content != base
+		remoteChanged := !remoteUnchanged && pasty.Content != base
+
+		switch {
+		case !localChanged:
+			pasties = append(pasties, EditPastyOptions{ID: pasty.ID, Title: pasty.Title, Content: pasty.Content, Language: pasty.Language})
+			fileOrder = append(fileOrder, file)
+		case remoteChanged && !(exists && content == pasty.Content) && !options.Force:
+			conflicts = append(conflicts, SyncConflict{
+				File:         file,
+				PastyID:      pasty.ID,
+				Base:         base,
+				Local:        content,
+				LocalExists:  exists,
+				Remote:       pasty.Content,
+				RemoteExists: true,
+			})
+		case !exists:
+			result.Removed = append(result.Removed, file)
+		default:
+			pasties = append(pasties, EditPastyOptions{ID: pasty.ID, Title: pasty.Title, Content: content, Language: pasty.Language})
+			fileOrder = append(fileOrder, file)
+			if content != pasty.Content {
+				result.Updated = append(result.Updated, file)
+			}
+		}
+	}
+
+	// Untracked local files become new pasties. Tracked files whose pasty was
+	// deleted remotely are deleted as well, unless they were edited, which is
+	// a conflict
+	var newFiles, localRemovals []string
+	for file, content := range local {
+		synced, ok := state.Files[file]
+		if !ok {
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		if _, ok := remotePasties[synced.PastyID]; ok {
+			continue
+		}
+
+		switch {
+		case content == synced.Base:
+			localRemovals = append(localRemovals, file)
+		case options.Force:
+			newFiles = append(newFiles, file)
+		default:
+			conflicts = append(conflicts, SyncConflict{
+				File:        file,
+				PastyID:     synced.PastyID,
+				Base:        synced.Base,
+				Local:       content,
+				LocalExists: true,
+			})
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, s.conflictError(ctx, conflicts, state, remote, opts)
+	}
+
+	sort.Strings(newFiles)
+	for _, file := range newFiles {
+		pasties = append(pasties, EditPastyOptions{
+			Title:    file,
+			Content:  local[file],
+			Language: extensionLanguages[strings.ToLower(filepath.Ext(file))],
+		})
+		fileOrder = append(fileOrder, file)
+		result.Added = append(result.Added, file)
+	}
+
+	if len(result.Added)+len(result.Updated)+len(result.Removed) == 0 {
+		if err := s.removeLocal(state, localRemovals, result); err != nil {
+			return nil, err
+		}
+		if len(localRemovals) > 0 {
+			if err := s.saveState(state); err != nil {
+				return nil, err
+			}
+		}
+
+		result.sort()
+		return result, nil
+	}
+
+	if len(pasties) == 0 {
+		return nil, fmt.Errorf("can't push an empty directory, a paste needs at least one pasty")
+	}
+
+	// The edit is based on the paste as fetched above, which already holds the
+	// remote edits made since the last sync
+	edited, err := s.client.EditPasteIfUnchanged(ctx, s.pasteID, RevisionOf(remote), EditPasteOptions{Title: remote.Title, Pasties: pasties}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.removeLocal(state, localRemovals, result); err != nil {
+		return nil, err
+	}
+
+	// Existing pasties are matched to their files by ID, new ones by the
+	// order they were sent in
+	fileByID := make(map[string]string)
+	var addedFiles []string
+	for i, pasty := range pasties {
+		switch {
+		case fileOrder[i] == "":
+		case pasty.ID != "":
+			fileByID[pasty.ID] = fileOrder[i]
+		default:
+			addedFiles = append(addedFiles, fileOrder[i])
+		}
+	}
+
+	newState := &syncState{PasteID: s.pasteID, EditedAt: edited.EditedAt, Files: make(map[string]syncedFile)}
+	for _, pasty := range edited.Pasties {
+		file, ok := fileByID[pasty.ID]
+		if !ok {
+			if len(addedFiles) == 0 {
+				continue
+			}
+			file, addedFiles = addedFiles[0], addedFiles[1:]
+		}
+		newState.Files[file] = syncedFile{PastyID: pasty.ID, Language: pasty.Language, Base: pasty.Content}
+	}
+
+	if err := s.saveState(newState); err != nil {
+		return nil, err
+	}
+
+	result.sort()
+	return result, nil
+}
+
+// removeLocal deletes files whose pasty was deleted remotely, along with their
+// entries in state.
+func (s *DirSync) removeLocal(state *syncState, files []string, result *SyncResult) error {
+	for _, file := range files {
+		if err := os.Remove(filepath.Join(s.dir, file)); err != nil {
+			return fmt.Errorf("could not remove %s: %w", file, err)
+		}
+		delete(state.Files, file)
+		result.Removed = append(result.Removed, file)
+	}
+
+	return nil
+}
+
+// conflictError builds a SyncConflictError, listing the remote revisions made
+// since the last sync when the history is available.
+func (s *DirSync) conflictError(ctx context.Context, conflicts []SyncConflict, state *syncState, remote *Paste, opts []RequestOption) error {
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].File < conflicts[j].File
+	})
+
+	conflictErr := &SyncConflictError{Conflicts: conflicts, Remote: remote}
+
+	history, err := s.client.GetCompactPasteHistory(ctx, s.pasteID, opts...)
+	if err != nil {
+		return conflictErr
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].EditedAt.Before(history[j].EditedAt)
+	})
+	for _, entry := range history {
+		if state.EditedAt == nil || entry.EditedAt.After(*state.EditedAt) {
+			conflictErr.RemoteRevisions = append(conflictErr.RemoteRevisions, entry.ID)
+		}
+	}
+
+	return conflictErr
+}
+
+// fileNameFor picks a file name for a pasty that has none yet, based on its
+// title and unique within the directory.
+func (s *DirSync) fileNameFor(pasty Pasty, state *syncState, local map[string]string, newState *syncState) string {
+	name := "pasty-" + pasty.ID
+	if pasty.Title != "" {
+		name = sanitizeFileName(pasty.Title)
+	}
+	if name == SyncStateFile {
+		name = "_" + name
+	}
+
+	taken := func(candidate string) bool {
+		if _, ok := newState.Files[candidate]; ok {
+			return true
+		}
+		synced, ok := state.Files[candidate]
+		return ok && synced.PastyID != pasty.ID
+	}
+
+	candidate := name
+	ext := filepath.Ext(name)
+	for i := 2; taken(candidate); i++ {
+		candidate = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+
+	return candidate
+}
+
+// readFiles returns the content of every regular, non-hidden file directly
+// inside the directory.
+func (s *DirSync) readFiles() (map[string]string, error) {
+	files := make(map[string]string)
+
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read sync directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", entry.Name(), err)
+		}
+		files[entry.Name()] = string(content)
+	}
+
+	return files, nil
+}
+
+func (s *DirSync) loadState() (*syncState, error) {
+	var state syncState
+
+	err := readJSONFile(filepath.Join(s.dir, SyncStateFile), &state)
+	if errors.Is(err, os.ErrNotExist) {
+		return &syncState{PasteID: s.pasteID, Files: make(map[string]syncedFile)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if state.PasteID != s.pasteID {
+		return nil, fmt.Errorf("directory %s is synced with paste %s, not %s", s.dir, state.PasteID, s.pasteID)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]syncedFile)
+	}
+
+	return &state, nil
+}
+
+func (s *DirSync) saveState(state *syncState) error {
+	state.SyncedAt = time.Now().UTC()
+	return writeJSONFile(filepath.Join(s.dir, SyncStateFile), state)
+}
+
+func (r *SyncResult) sort() {
+	sort.Strings(r.Added)
+	sort.Strings(r.Updated)
+	sort.Strings(r.Removed)
+}
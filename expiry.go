@@ -0,0 +1,165 @@
+package gopastemyst
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// ExpiringPaste is a paste that will be deleted within the window given to
+// FindExpiringPastes.
+type ExpiringPaste struct {
+	Paste     Paste
+	DeletesAt time.Time
+	Remaining time.Duration
+}
+
+// FindExpiringPastes returns the pastes whose DeletesAt falls within the
+// given window from now, soonest first. Pastes that never expire are skipped,
+// and so are pastes that already expired, since the API has deleted them.
+func FindExpiringPastes(pastes []Paste, within time.Duration) []ExpiringPaste {
+	now := time.Now()
+	deadline := now.Add(within)
+
+	var expiring []ExpiringPaste
+	for _, paste := range pastes {
+		if paste.DeletesAt == nil || paste.DeletesAt.After(deadline) || expired(paste.DeletesAt) {
+			continue
+		}
+
+		expiring = append(expiring, ExpiringPaste{
+			Paste:     paste,
+			DeletesAt: *paste.DeletesAt,
+			Remaining: paste.DeletesAt.Sub(now),
+		})
+	}
+
+	sort.Slice(expiring, func(i, j int) bool {
+		return expiring[i].DeletesAt.Before(expiring[j].DeletesAt)
+	})
+
+	return expiring
+}
+
+// FindExpiringUserPastes scans every paste of a user and reports those that
+// will be deleted within the given window.
+func (c *Client) FindExpiringUserPastes(ctx context.Context, username string, within time.Duration, opts ...RequestOption) ([]ExpiringPaste, error) {
+	pastes, err := c.GetAllUserPastes(ctx, username, "", opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not list pastes of %s: %w", username, err)
+	}
+
+	return FindExpiringPastes(pastes, within), nil
+}
+
+// FindExpiringPastesByID fetches the given pastes and reports those that will
+// be deleted within the given window. Pastes that can't be fetched are
+// reported in the returned error, the rest are still checked.
+func (c *Client) FindExpiringPastesByID(ctx context.Context, pasteIDs []string, within time.Duration, opts ...RequestOption) ([]ExpiringPaste, error) {
+	var pastes []Paste
+	var errs []error
+
+	for _, result := range c.GetPastes(ctx, pasteIDs, BatchOptions{}, opts...) {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("paste %s: %w", result.ID, result.Err))
+			continue
+		}
+		pastes = append(pastes, *result.Paste)
+	}
+
+	return FindExpiringPastes(pastes, within), errors.Join(errs...)
+}
+
+type RenewOptions struct {
+	// Expiry of the re-created pastes, the original ExpiresIn is kept if empty
+	ExpiresIn string
+}
+
+// RenewPastes re-creates each paste with the same title, tags, visibility and
+// pasties, and returns a mapping from old to new paste IDs. Pastes that fail
+// to be re-created are left out of the mapping and reported in the error.
+func (c *Client) RenewPastes(ctx context.Context, pastes []Paste, options RenewOptions, opts ...RequestOption) (map[string]string, error) {
+	mapping := make(map[string]string)
+	var errs []error
+
+	for _, paste := range pastes {
+		expiresIn := options.ExpiresIn
+		if expiresIn == "" {
+			expiresIn = paste.ExpiresIn
+		}
+
+		pasties := make([]CreatePastyOptions, 0, len(paste.Pasties))
+		for _, pasty := range paste.Pasties {
+			pasties = append(pasties, CreatePastyOptions{
+				Title:    pasty.Title,
+				Content:  pasty.Content,
+				Language: pasty.Language,
+			})
+		}
+
+		renewed, err := c.CreatePaste(ctx, CreatePasteOptions{
+			Title:     paste.Title,
+			ExpiresIn: expiresIn,
+			Private:   paste.Private,
+			Pinned:    paste.Pinned,
+			Tags:      paste.Tags,
+			Pasties:   pasties,
+		}, appendOptions(opts, withIdempotencySuffix(paste.ID))...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not renew paste %s: %w", paste.ID, err))
+			continue
+		}
+
+		mapping[paste.ID] = renewed.ID
+	}
+
+	return mapping, errors.Join(errs...)
+}
+
+// LoadRenewMapping reads an old to new paste ID mapping written by
+// SaveRenewMapping. A missing file yields an empty mapping.
+func LoadRenewMapping(path string) (map[string]string, error) {
+	mapping := make(map[string]string)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return mapping, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read mapping file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("could not decode mapping file: %w", err)
+	}
+
+	return mapping, nil
+}
+
+// SaveRenewMapping adds mapping to the JSON file at path, keeping the entries
+// written by earlier runs.
+func SaveRenewMapping(path string, mapping map[string]string) error {
+	existing, err := LoadRenewMapping(path)
+	if err != nil {
+		return err
+	}
+
+	for oldID, newID := range mapping {
+		existing[oldID] = newID
+	}
+
+	data, err := json.MarshalIndent(existing, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode mapping: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("could not write mapping file: %w", err)
+	}
+
+	return nil
+}
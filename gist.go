@@ -0,0 +1,280 @@
+package gopastemyst
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Check https://docs.github.com/en/rest/gists/gists
+
+// Gist is the subset of the GitHub Gist API format needed for importing.
+type Gist struct {
+	ID          string              `json:"id"`
+	Description string              `json:"description"`
+	Public      bool                `json:"public"`
+	Files       map[string]GistFile `json:"files"`
+}
+
+type GistFile struct {
+	Filename  string `json:"filename"`
+	Language  string `json:"language"`
+	Content   string `json:"content"`
+	Truncated bool   `json:"truncated"`
+}
+
+// Languages guessed from file extensions when a file has none, as is the case
+// for cloned gists and synced directories. Names follow GitHub Linguist, like
+// PasteMyst does.
+var extensionLanguages = map[string]string{
+	".c":     "C",
+	".h":     "C",
+	".cpp":   "C++",
+	".hpp":   "C++",
+	".cs":    "C#",
+	".css":   "CSS",
+	".d":     "D",
+	".go":    "Go",
+	".html":  "HTML",
+	".java":  "Java",
+	".js":    "JavaScript",
+	".json":  "JSON",
+	".kt":    "Kotlin",
+	".lua":   "Lua",
+	".md":    "Markdown",
+	".php":   "PHP",
+	".py":    "Python",
+	".rb":    "Ruby",
+	".rs":    "Rust",
+	".sh":    "Shell",
+	".sql":   "SQL",
+	".swift": "Swift",
+	".toml":  "TOML",
+	".ts":    "TypeScript",
+	".txt":   "Text",
+	".xml":   "XML",
+	".yaml":  "YAML",
+	".yml":   "YAML",
+	".zig":   "Zig",
+}
+
+// ParseGists reads either a single gist or a list of gists in the GitHub
+// Gist API JSON format.
+func ParseGists(r io.Reader) ([]Gist, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read gist JSON: %w", err)
+	}
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var gists []Gist
+		if err := json.Unmarshal(data, &gists); err != nil {
+			return nil, fmt.Errorf("could not decode gist list: %w", err)
+		}
+		return gists, nil
+	}
+
+	var gist Gist
+	if err := json.Unmarshal(data, &gist); err != nil {
+		return nil, fmt.Errorf("could not decode gist: %w", err)
+	}
+
+	return []Gist{gist}, nil
+}
+
+// LoadGistDir reads a cloned gist from dir. A clone has no description or
+// visibility, so the directory name becomes the gist description and the gist
+// is treated as public.
+func LoadGistDir(dir string) (*Gist, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read gist directory: %w", err)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve gist directory: %w", err)
+	}
+
+	gist := &Gist{
+		ID:          filepath.Base(abs),
+		Description: filepath.Base(abs),
+		Public:      true,
+		Files:       make(map[string]GistFile),
+	}
+
+	for _, entry := range entries {
+		// Skips .git and any other hidden files
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read gist file: %w", err)
+		}
+
+		gist.Files[entry.Name()] = GistFile{
+			Filename: entry.Name(),
+			Content:  string(content),
+		}
+	}
+
+	return gist, nil
+}
+
+type GistImportOptions struct {
+	// Number of pastes created at the same time, defaults to 1
+	BatchSize int
+
+	// Only print what would be created, without calling the API
+	DryRun bool
+
+	// Where progress is printed, nothing is printed if nil
+	Output io.Writer
+
+	// Applied to every created paste
+	ExpiresIn string
+	Tags      []string
+
+	// Maps gist language names or file extensions (with the leading dot) to
+	// PasteMyst language names, taking precedence over the built-in mapping
+	LanguageOverrides map[string]string
+}
+
+// GistToCreatePasteOptions converts a gist into options for CreatePaste. The
+// description becomes the title, each file a pasty and secret gists become
+// private pastes.
+func GistToCreatePasteOptions(gist Gist, options GistImportOptions) (CreatePasteOptions, error) {
+	names := make([]string, 0, len(gist.Files))
+	for name := range gist.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	paste := CreatePasteOptions{
+		Title:     gist.Description,
+		ExpiresIn: options.ExpiresIn,
+		Private:   !gist.Public,
+		Tags:      options.Tags,
+	}
+
+	for _, name := range names {
+		file := gist.Files[name]
+		if file.Filename == "" {
+			file.Filename = name
+		}
+
+		if file.Truncated {
+			return CreatePasteOptions{}, fmt.Errorf("file %s of gist %s is truncated, import a clone of the gist instead", file.Filename, gist.ID)
+		}
+
+		paste.Pasties = append(paste.Pasties, CreatePastyOptions{
+			Title:    file.Filename,
+			Content:  file.Content,
+			Language: gistLanguage(file, options.LanguageOverrides),
+		})
+	}
+
+	if len(paste.Pasties) == 0 {
+		return CreatePasteOptions{}, fmt.Errorf("gist %s has no files", gist.ID)
+	}
+
+	return paste, nil
+}
+
+// gistLanguage picks the PasteMyst language of a gist file. An empty result
+// leaves the language up to the server's detection.
+func gistLanguage(file GistFile, overrides map[string]string) string {
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+
+	if file.Language != "" {
+		if language, ok := overrides[file.Language]; ok {
+			return language
+		}
+		return file.Language
+	}
+
+	if language, ok := overrides[ext]; ok {
+		return language
+	}
+
+	return extensionLanguages[ext]
+}
+
+// ImportGists creates one paste per gist, BatchSize at a time, and returns a
+// mapping from gist IDs to new paste IDs. Gists that fail to convert or be
+// created are reported in the error and do not stop the others.
+func (c *Client) ImportGists(ctx context.Context, gists []Gist, options GistImportOptions, opts ...RequestOption) (map[string]string, error) {
+	batchSize := max(options.BatchSize, 1)
+
+	var mu sync.Mutex
+	mapping := make(map[string]string)
+	var errs []error
+
+	report := func(format string, args ...any) {
+		if options.Output != nil {
+			fmt.Fprintf(options.Output, format+"\n", args...)
+		}
+	}
+
+	for start := 0; start < len(gists); start += batchSize {
+		batch := gists[start:min(start+batchSize, len(gists))]
+
+		var wg sync.WaitGroup
+		for _, gist := range batch {
+			paste, err := GistToCreatePasteOptions(gist, options)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				report("gist %s: skipped: %v", gist.ID, err)
+				mu.Unlock()
+				continue
+			}
+
+			if options.DryRun {
+				mu.Lock()
+				report("gist %s: would create paste %q with %d pasties (private: %t)",
+					gist.ID, paste.Title, len(paste.Pasties), paste.Private)
+				mu.Unlock()
+				continue
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				created, err := c.CreatePaste(ctx, paste, appendOptions(opts, withIdempotencySuffix(gist.ID))...)
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				if err != nil {
+					errs = append(errs, fmt.Errorf("gist %s: %w", gist.ID, err))
+					report("gist %s: failed: %v", gist.ID, err)
+					return
+				}
+
+				mapping[gist.ID] = created.ID
+				report("gist %s: created paste %s", gist.ID, created.ID)
+			}()
+		}
+		wg.Wait()
+
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+
+	return mapping, errors.Join(errs...)
+}
@@ -0,0 +1,338 @@
+package gopastemyst
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sammie156/go-pastemyst/internal/fileutil"
+)
+
+// DefaultIdempotencyLookback is how many pages of the owner's pastes are
+// searched for a paste created by an attempt whose outcome is unknown.
+const DefaultIdempotencyLookback = 3
+
+// ErrIdempotencyKeyReused is returned by CreatePaste when an idempotency key
+// already recorded in the journal is sent with different content.
+var ErrIdempotencyKeyReused = errors.New("idempotency key was used for different content")
+
+// NewIdempotencyKey returns a random key to pass to WithIdempotencyKey. Keep
+// it for as long as the call may be retried.
+func NewIdempotencyKey() string {
+	key := make([]byte, 16)
+	rand.Read(key)
+	return hex.EncodeToString(key)
+}
+
+// IdempotencyRecord is what the journal knows about a paste created with an
+// idempotency key.
+type IdempotencyRecord struct {
+	Key         string    `json:"key"`
+	ContentHash string    `json:"contentHash"`
+	StartedAt   time.Time `json:"startedAt"`
+
+	// Empty while no attempt is known to have succeeded
+	PasteID string `json:"pasteID,omitempty"`
+}
+
+// IdempotencyJournal stores idempotency records by key. Implementations must
+// be safe for concurrent use.
+type IdempotencyJournal interface {
+	Get(key string) (*IdempotencyRecord, bool)
+	Put(record *IdempotencyRecord) error
+}
+
+// IdempotencyOptions configures WithIdempotency.
+type IdempotencyOptions struct {
+	// Where records are kept, in memory when nil
+	Journal IdempotencyJournal
+
+	// Username of the client's token. When set, a retry after an attempt with
+	// an unknown outcome first looks through the user's pastes for one with
+	// the same content, for servers without native idempotency support.
+	Owner string
+
+	// Pages of the owner's pastes to search, DefaultIdempotencyLookback when
+	// zero
+	LookbackPages int
+}
+
+// WithIdempotency makes CreatePaste calls given WithIdempotencyKey return the
+// paste created by an earlier call with the same key instead of creating it
+// again. The key is still sent to the API, for servers handling it natively.
+// Concurrent calls with the same key run one after the other, so only the
+// first creates the paste. That only holds within the client, processes
+// sharing a DiskJournal aren't coordinated.
+func WithIdempotency(options IdempotencyOptions) ClientOption {
+	return func(c *Client) {
+		if options.Journal == nil {
+			options.Journal = NewMemoryJournal()
+		}
+		if options.LookbackPages <= 0 {
+			options.LookbackPages = DefaultIdempotencyLookback
+		}
+		c.idempotency = &options
+	}
+}
+
+// createIdempotent runs create unless the journal shows the paste for key was
+// already created, in which case that paste is returned.
+func (c *Client) createIdempotent(ctx context.Context, key string, options CreatePasteOptions, opts []RequestOption, create func() (*Paste, error)) (*Paste, error) {
+	// Without this, concurrent calls would all miss the record and create
+	// the paste
+	unlock, err := c.idempotencyLocks.lock(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	journal := c.idempotency.Journal
+	contentHash := createContentHash(options)
+
+	record, ok := journal.Get(key)
+	if ok {
+		if record.ContentHash != contentHash {
+			return nil, fmt.Errorf("%w: %s", ErrIdempotencyKeyReused, key)
+		}
+
+		if record.PasteID != "" {
+			return c.fetchPaste(ctx, record.PasteID, opts)
+		}
+
+		// An earlier attempt may have created the paste without us hearing back
+		paste, err := c.findCreatedPaste(ctx, record, opts)
+		if err != nil {
+			return nil, err
+		}
+		if paste != nil {
+			record.PasteID = paste.ID
+			journal.Put(record)
+			return paste, nil
+		}
+	} else {
+		record = &IdempotencyRecord{Key: key, ContentHash: contentHash, StartedAt: time.Now()}
+		if err := journal.Put(record); err != nil {
+			return nil, fmt.Errorf("could not record idempotency key: %w", err)
+		}
+	}
+
+	paste, err := create()
+	if err != nil {
+		return nil, err
+	}
+
+	// Failing to record the ID only costs a lookup on the next retry
+	record.PasteID = paste.ID
+	journal.Put(record)
+
+	return paste, nil
+}
+
+// findCreatedPaste looks through the owner's most recent pastes for one created
+// since record was started with the same content. It returns nil when there
+// is no owner to search or no such paste.
+func (c *Client) findCreatedPaste(ctx context.Context, record *IdempotencyRecord, opts []RequestOption) (*Paste, error) {
+	owner := c.idempotency.Owner
+	if owner == "" {
+		return nil, nil
+	}
+
+	// Leave room for clock skew between us and the server
+	since := record.StartedAt.Add(-time.Minute)
+
+	for page := 0; page < c.idempotency.LookbackPages; page++ {
+		result, err := c.GetUserPastes(ctx, owner, GetUserPasteOptions{Page: page}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("could not look for created paste: %w", err)
+		}
+
+		for i := range result.Items {
+			paste := &result.Items[i]
+			if paste.CreatedAt.After(since) && pasteContentHash(paste) == record.ContentHash {
+				return paste, nil
+			}
+		}
+
+		if !result.HasNextPage {
+			break
+		}
+	}
+
+	return nil, nil
+}
+
+// createContentHash and pasteContentHash hash a paste's title and pasty
+// contents, so a created paste can be matched with the options it was
+// created from.
+func createContentHash(options CreatePasteOptions) string {
+	h := sha256.New()
+	writeHashField(h, options.Title)
+	for _, pasty := range options.Pasties {
+		writeHashField(h, pasty.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func pasteContentHash(paste *Paste) string {
+	h := sha256.New()
+	writeHashField(h, paste.Title)
+	for _, pasty := range paste.Pasties {
+		writeHashField(h, pasty.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeHashField writes s prefixed with its length, so field boundaries
+// can't be shifted without changing the hash.
+func writeHashField(h hash.Hash, s string) {
+	h.Write([]byte(strconv.Itoa(len(s)) + ":"))
+	h.Write([]byte(s))
+}
+
+// keyLocks hands out one lock per key, dropping locks nobody holds or waits
+// for. The zero value is ready to use.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	held chan struct{}
+	refs int
+}
+
+// lock waits until the lock for key is free or ctx is done, and returns the
+// function releasing it.
+func (k *keyLocks) lock(ctx context.Context, key string) (func(), error) {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyLock)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{held: make(chan struct{}, 1)}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	release := func() {
+		k.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+
+	select {
+	case l.held <- struct{}{}:
+		return func() {
+			<-l.held
+			release()
+		}, nil
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// ----- IN-MEMORY JOURNAL -----
+
+// MemoryJournal is an IdempotencyJournal kept in memory, covering retries
+// within a single process.
+type MemoryJournal struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+// NewMemoryJournal creates an empty MemoryJournal.
+func NewMemoryJournal() *MemoryJournal {
+	return &MemoryJournal{records: make(map[string]IdempotencyRecord)}
+}
+
+func (m *MemoryJournal) Get(key string) (*IdempotencyRecord, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.records[key]
+	if !ok {
+		return nil, false
+	}
+
+	return &record, true
+}
+
+func (m *MemoryJournal) Put(record *IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records[record.Key] = *record
+	return nil
+}
+
+// ----- ON-DISK JOURNAL -----
+
+// DiskJournal is an IdempotencyJournal keeping one JSON file per key inside a
+// directory, so retries are recognized across process restarts.
+type DiskJournal struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewDiskJournal creates a DiskJournal rooted at dir, creating it if needed.
+func NewDiskJournal(dir string) (*DiskJournal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create journal directory: %w", err)
+	}
+
+	return &DiskJournal{dir: dir}, nil
+}
+
+func (d *DiskJournal) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (d *DiskJournal) Get(key string) (*IdempotencyRecord, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var record IdempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil || record.Key != key {
+		return nil, false
+	}
+
+	return &record, true
+}
+
+func (d *DiskJournal) Put(record *IdempotencyRecord) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("could not encode journal record: %w", err)
+	}
+
+	if err := fileutil.WriteFileAtomic(d.dir, d.path(record.Key), data); err != nil {
+		return fmt.Errorf("could not write journal record: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+// Package fileutil holds file helpers shared by gopastemyst and its server.
+package fileutil
+
+import (
+	"os"
+)
+
+// WriteFileAtomic writes data to path through a temporary file in dir, which
+// must be on the same filesystem, so readers and crashes never see a partial
+// file. The file is only readable by the current user.
+func WriteFileAtomic(dir string, path string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
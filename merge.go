@@ -0,0 +1,545 @@
+package gopastemyst
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Markers written around conflicting lines, in the same layout git uses
+const (
+	conflictLocalMarker  = "<<<<<<< local\n"
+	conflictBaseMarker   = "||||||| base\n"
+	conflictSplitMarker  = "=======\n"
+	conflictRemoteMarker = ">>>>>>> remote\n"
+)
+
+// MergeConflict is a region of a text that was changed differently on both
+// sides. Line numbers are 1-based and point at where the region starts in
+// each version.
+type MergeConflict struct {
+	BaseLine   int
+	LocalLine  int
+	RemoteLine int
+
+	Base   string
+	Local  string
+	Remote string
+}
+
+// MergeResult is the outcome of a three-way merge. When Conflicts is not
+// empty, Content holds conflict markers around each conflicting region.
+type MergeResult struct {
+	Content   string
+	Conflicts []MergeConflict
+}
+
+// Merge3 merges the changes made to base in local and in remote, line by
+// line. Regions changed on only one side, or changed the same way on both,
+// merge cleanly. Other regions are reported as conflicts and written with
+// git style conflict markers.
+func Merge3(base, local, remote string) MergeResult {
+	baseLines := splitLines(base)
+	localLines := splitLines(local)
+	remoteLines := splitLines(remote)
+
+	toLocal := lineMatches(baseLines, localLines)
+	toRemote := lineMatches(baseLines, remoteLines)
+
+	var out strings.Builder
+	var result MergeResult
+
+	i, j, k := 0, 0, 0
+	for {
+		// Lines unchanged on both sides
+		n := 0
+		for i+n < len(baseLines) && toLocal[i+n] == j+n && toRemote[i+n] == k+n {
+			n++
+		}
+		if n > 0 {
+			writeLines(&out, baseLines[i:i+n], false)
+			i, j, k = i+n, j+n, k+n
+			continue
+		}
+
+		// Find the next base line both sides still have, everything before it
+		// was changed on at least one side
+		o := i
+		for o < len(baseLines) && (toLocal[o] < 0 || toRemote[o] < 0) {
+			o++
+		}
+
+		nextLocal, nextRemote := len(localLines), len(remoteLines)
+		if o < len(baseLines) {
+			nextLocal, nextRemote = toLocal[o], toRemote[o]
+		}
+
+		baseChunk := baseLines[i:o]
+		localChunk := localLines[j:nextLocal]
+		remoteChunk := remoteLines[k:nextRemote]
+
+		switch {
+		case equalLines(localChunk, baseChunk):
+			writeLines(&out, remoteChunk, false)
+		case equalLines(remoteChunk, baseChunk), equalLines(localChunk, remoteChunk):
+			writeLines(&out, localChunk, false)
+		default:
+			result.Conflicts = append(result.Conflicts, MergeConflict{
+				BaseLine:   i + 1,
+				LocalLine:  j + 1,
+				RemoteLine: k + 1,
+				Base:       strings.Join(baseChunk, ""),
+				Local:      strings.Join(localChunk, ""),
+				Remote:     strings.Join(remoteChunk, ""),
+			})
+
+			out.WriteString(conflictLocalMarker)
+			writeLines(&out, localChunk, true)
+			out.WriteString(conflictBaseMarker)
+			writeLines(&out, baseChunk, true)
+			out.WriteString(conflictSplitMarker)
+			writeLines(&out, remoteChunk, true)
+			out.WriteString(conflictRemoteMarker)
+		}
+
+		if o == len(baseLines) {
+			break
+		}
+		i, j, k = o, nextLocal, nextRemote
+	}
+
+	result.Content = out.String()
+	return result
+}
+
+// splitLines splits s after every newline. The last line may lack one.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeLines writes lines to out. With terminate set, the last line gets a
+// newline if it has none, so a conflict marker that follows starts on its own
+// line.
+func writeLines(out *strings.Builder, lines []string, terminate bool) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+	if terminate && len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		out.WriteString("\n")
+	}
+}
+
+// lineMatches returns, for every line of a, the index of the line of b it is
+// matched with in a longest common subsequence, or -1.
+func lineMatches(a, b []string) []int {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	// Edits are usually small, so matching the common prefix and suffix up
+	// front keeps the diff below cheap
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		matches[prefix] = prefix
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		matches[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+
+	for _, pair := range myersPairs(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		matches[prefix+pair[0]] = prefix + pair[1]
+	}
+
+	return matches
+}
+
+// myersPairs finds a longest common subsequence of a and b with Myers' diff
+// algorithm and returns the matched index pairs in increasing order.
+func myersPairs(a, b []string) [][2]int {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return nil
+	}
+
+	offset := n + m
+	v := make([]int, 2*offset+2)
+
+	// trace[d] holds v[-d..d] as it was before round d
+	var trace [][]int
+
+	end := 0
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				end = d
+				break search
+			}
+		}
+	}
+
+	var pairs [][2]int
+	x, y := n, m
+	for d := end; d > 0; d-- {
+		previous := trace[d]
+		at := func(k int) int { return previous[k+d] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			pairs = append(pairs, [2]int{x, y})
+		}
+		x, y = prevX, prevY
+	}
+
+	for x > 0 && y > 0 {
+		x--
+		y--
+		pairs = append(pairs, [2]int{x, y})
+	}
+
+	for left, right := 0, len(pairs)-1; left < right; left, right = left+1, right-1 {
+		pairs[left], pairs[right] = pairs[right], pairs[left]
+	}
+
+	return pairs
+}
+
+// ----- PASTE MERGING -----
+
+type MergeOutcome int
+
+const (
+	// Neither side changed the pasty
+	MergeUnchanged MergeOutcome = iota
+	// Only the local edit changed the pasty
+	MergeTookLocal
+	// Only the remote paste changed the pasty
+	MergeTookRemote
+	// Both sides changed the pasty and the changes were combined
+	MergeCombined
+	// The pasty was added by one side
+	MergeAdded
+	// The pasty was removed by one side and left untouched by the other
+	MergeDeleted
+	// The changes could not be combined, see the report's Conflicts
+	MergeConflicted
+)
+
+func (o MergeOutcome) String() string {
+	switch o {
+	case MergeUnchanged:
+		return "unchanged"
+	case MergeTookLocal:
+		return "took local"
+	case MergeTookRemote:
+		return "took remote"
+	case MergeCombined:
+		return "combined"
+	case MergeAdded:
+		return "added"
+	case MergeDeleted:
+		return "deleted"
+	case MergeConflicted:
+		return "conflicted"
+	default:
+		return fmt.Sprintf("MergeOutcome(%d)", int(o))
+	}
+}
+
+// PastyMergeReport describes how one pasty was merged. Conflicts lists the
+// conflicting regions of its content. A pasty deleted on one side and
+// changed on the other is conflicted with no regions.
+type PastyMergeReport struct {
+	PastyID   string
+	Title     string
+	Outcome   MergeOutcome
+	Conflicts []MergeConflict
+}
+
+type MergeReport struct {
+	Pasties []PastyMergeReport
+}
+
+// HasConflicts reports whether any pasty failed to merge cleanly.
+func (r *MergeReport) HasConflicts() bool {
+	for _, pasty := range r.Pasties {
+		if pasty.Outcome == MergeConflicted {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MergeConflictError is returned by a MergeFunc from ThreeWayMerge when the
+// edit could not be merged cleanly.
+type MergeConflictError struct {
+	Report *MergeReport
+}
+
+func (e *MergeConflictError) Error() string {
+	var titles []string
+	for _, pasty := range e.Report.Pasties {
+		if pasty.Outcome == MergeConflicted {
+			titles = append(titles, fmt.Sprintf("%q", pasty.Title))
+		}
+	}
+
+	return fmt.Sprintf("merge conflict in %s", strings.Join(titles, ", "))
+}
+
+// MergePasties merges a local edit and the remote paste, both made on top of
+// base. Pasties are matched by ID. Local pasties without an ID, or with one
+// that is in neither base nor remote, are new and are appended after the
+// remote ones without an ID. Empty titles and languages in the edit mean
+// unchanged, as they do for EditPaste.
+//
+// Conflicting content is written with conflict markers. A pasty deleted on
+// one side and changed on the other is kept.
+func MergePasties(base *Paste, local EditPasteOptions, remote *Paste) (EditPasteOptions, *MergeReport) {
+	report := &MergeReport{}
+
+	basePasties := make(map[string]Pasty)
+	for _, pasty := range base.Pasties {
+		basePasties[pasty.ID] = pasty
+	}
+
+	localPasties := make(map[string]EditPastyOptions)
+	for _, pasty := range local.Pasties {
+		if pasty.ID != "" {
+			localPasties[pasty.ID] = pasty
+		}
+	}
+
+	localTitle := local.Title
+	if localTitle == "" {
+		localTitle = base.Title
+	}
+
+	merged := EditPasteOptions{
+		Title: mergeScalar(base.Title, localTitle, remote.Title),
+	}
+
+	seen := make(map[string]bool)
+	for _, remotePasty := range remote.Pasties {
+		seen[remotePasty.ID] = true
+		basePasty, inBase := basePasties[remotePasty.ID]
+		localPasty, inLocal := localPasties[remotePasty.ID]
+
+		entry := PastyMergeReport{PastyID: remotePasty.ID, Title: remotePasty.Title}
+
+		switch {
+		case !inBase && !inLocal:
+			// Added remotely
+			entry.Outcome = MergeAdded
+			merged.Pasties = append(merged.Pasties, editPastyFrom(remotePasty))
+
+		case inBase && !inLocal:
+			// Deleted locally
+			if remotePasty == basePasty {
+				entry.Outcome = MergeDeleted
+			} else {
+				entry.Outcome = MergeConflicted
+				merged.Pasties = append(merged.Pasties, editPastyFrom(remotePasty))
+			}
+
+		default:
+			localPasty = withPastyDefaults(localPasty, basePasty)
+
+			content := Merge3(basePasty.Content, localPasty.Content, remotePasty.Content)
+			pasty := EditPastyOptions{
+				ID:       remotePasty.ID,
+				Title:    mergeScalar(basePasty.Title, localPasty.Title, remotePasty.Title),
+				Language: mergeScalar(basePasty.Language, localPasty.Language, remotePasty.Language),
+				Content:  content.Content,
+			}
+			entry.Title = pasty.Title
+			entry.Conflicts = content.Conflicts
+
+			localChanged := localPasty.Title != basePasty.Title || localPasty.Language != basePasty.Language || localPasty.Content != basePasty.Content
+			remoteChanged := remotePasty.Title != basePasty.Title || remotePasty.Language != basePasty.Language || remotePasty.Content != basePasty.Content
+
+			switch {
+			case len(content.Conflicts) > 0:
+				entry.Outcome = MergeConflicted
+			case localChanged && remoteChanged:
+				entry.Outcome = MergeCombined
+			case localChanged:
+				entry.Outcome = MergeTookLocal
+			case remoteChanged:
+				entry.Outcome = MergeTookRemote
+			}
+
+			merged.Pasties = append(merged.Pasties, pasty)
+		}
+
+		report.Pasties = append(report.Pasties, entry)
+	}
+
+	for _, localPasty := range local.Pasties {
+		if localPasty.ID != "" && seen[localPasty.ID] {
+			continue
+		}
+
+		basePasty, inBase := basePasties[localPasty.ID]
+		entry := PastyMergeReport{PastyID: localPasty.ID, Title: localPasty.Title}
+
+		switch {
+		case localPasty.ID == "" || !inBase:
+			// Added locally. An ID the paste never had can't be edited, so the
+			// pasty is sent as a new one
+			entry.Outcome = MergeAdded
+			localPasty.ID = ""
+			merged.Pasties = append(merged.Pasties, localPasty)
+
+		default:
+			// Deleted remotely
+			localPasty = withPastyDefaults(localPasty, basePasty)
+			entry.Title = localPasty.Title
+			if localPasty.Title == basePasty.Title && localPasty.Language == basePasty.Language && localPasty.Content == basePasty.Content {
+				entry.Outcome = MergeDeleted
+			} else {
+				entry.Outcome = MergeConflicted
+				// The old ID no longer exists, so it comes back as a new pasty
+				localPasty.ID = ""
+				merged.Pasties = append(merged.Pasties, localPasty)
+			}
+		}
+
+		report.Pasties = append(report.Pasties, entry)
+	}
+
+	return merged, report
+}
+
+// mergeScalar merges a single value: a side that changed it wins, and the
+// local side wins when both did.
+func mergeScalar(base, local, remote string) string {
+	if local == base {
+		return remote
+	}
+
+	return local
+}
+
+func withPastyDefaults(pasty EditPastyOptions, base Pasty) EditPastyOptions {
+	if pasty.Title == "" {
+		pasty.Title = base.Title
+	}
+	if pasty.Language == "" {
+		pasty.Language = base.Language
+	}
+
+	return pasty
+}
+
+func editPastyFrom(pasty Pasty) EditPastyOptions {
+	return EditPastyOptions{
+		ID:       pasty.ID,
+		Title:    pasty.Title,
+		Content:  pasty.Content,
+		Language: pasty.Language,
+	}
+}
+
+type ThreeWayMergeOptions struct {
+	// Accept merges with conflicts and send the content with conflict
+	// markers, instead of failing with a MergeConflictError
+	AllowConflicts bool
+
+	// Called with the report of every merge
+	OnMerge func(*MergeReport)
+}
+
+// ThreeWayMerge returns a MergeFunc for EditPasteWithRetry that merges the
+// edit with the current paste, using base as the common ancestor. A merged
+// edit is based on the paste it was merged with, so that paste becomes the
+// common ancestor of the next merge.
+func ThreeWayMerge(base *Paste, options ThreeWayMergeOptions) MergeFunc {
+	return func(local EditPasteOptions, current *Paste) (EditPasteOptions, error) {
+		merged, report := MergePasties(base, local, current)
+
+		if options.OnMerge != nil {
+			options.OnMerge(report)
+		}
+		if report.HasConflicts() && !options.AllowConflicts {
+			return EditPasteOptions{}, &MergeConflictError{Report: report}
+		}
+
+		base = current
+		return merged, nil
+	}
+}
+
+// MergeWithRevision merges a local edit based on the revision historyID of a
+// paste with the paste as it is now. The base revision is loaded with
+// GetPasteAtSpecificEdit.
+func (c *Client) MergeWithRevision(ctx context.Context, pasteID string, historyID string, local EditPasteOptions, opts ...RequestOption) (EditPasteOptions, *MergeReport, error) {
+	base, err := c.GetPasteAtSpecificEdit(ctx, pasteID, historyID, opts...)
+	if err != nil {
+		return EditPasteOptions{}, nil, fmt.Errorf("could not load base revision: %w", err)
+	}
+
+	remote, err := c.fetchPaste(ctx, pasteID, opts)
+	if err != nil {
+		return EditPasteOptions{}, nil, err
+	}
+
+	merged, report := MergePasties(base, local, remote)
+	return merged, report, nil
+}
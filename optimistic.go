@@ -0,0 +1,122 @@
+package gopastemyst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultEditAttempts is how many times EditPasteWithRetry tries an edit when
+// no attempt count is given.
+const DefaultEditAttempts = 3
+
+// ErrConflict is matched by errors.Is when an edit was refused because the
+// paste changed since the expected revision. Use errors.As with a
+// *ConflictError to get the current paste.
+var ErrConflict = errors.New("paste was edited concurrently")
+
+// ConflictError is returned by EditPasteIfUnchanged when the paste is no
+// longer at the expected revision. Current is the paste as it is now.
+type ConflictError struct {
+	PasteID  string
+	Expected Revision
+	Current  *Paste
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("paste %s: %s", e.PasteID, ErrConflict)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Revision identifies the state of a paste an edit is based on, either by
+// the paste's EditedAt or by the ID of its latest history entry. When
+// HistoryID is set EditedAt is ignored. The zero Revision means the paste was
+// never edited.
+type Revision struct {
+	EditedAt  *time.Time
+	HistoryID string
+}
+
+// RevisionOf returns the revision paste is at.
+func RevisionOf(paste *Paste) Revision {
+	return Revision{EditedAt: paste.EditedAt}
+}
+
+// EditPasteIfUnchanged applies options to the paste only if it is still at
+// the expected revision, and returns a *ConflictError otherwise.
+//
+// The API has no conditional edits, so the revision is checked right before
+// the edit is sent. This closes all but a very small window for concurrent
+// edits to be lost.
+func (c *Client) EditPasteIfUnchanged(ctx context.Context, pasteID string, expected Revision, options EditPasteOptions, opts ...RequestOption) (*Paste, error) {
+	current, err := c.fetchPaste(ctx, pasteID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	unchanged, err := c.atRevision(ctx, current, expected, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !unchanged {
+		return nil, &ConflictError{PasteID: pasteID, Expected: expected, Current: current}
+	}
+
+	return c.EditPaste(ctx, pasteID, options, opts...)
+}
+
+func (c *Client) atRevision(ctx context.Context, current *Paste, expected Revision, opts []RequestOption) (bool, error) {
+	if expected.HistoryID == "" {
+		return sameTime(current.EditedAt, expected.EditedAt), nil
+	}
+
+	history, err := c.GetCompactPasteHistory(ctx, current.ID, opts...)
+	if err != nil {
+		return false, fmt.Errorf("could not check paste revision: %w", err)
+	}
+
+	var latest *CompactPasteHistory
+	for i := range history {
+		if latest == nil || history[i].EditedAt.After(latest.EditedAt) {
+			latest = &history[i]
+		}
+	}
+
+	return latest != nil && latest.ID == expected.HistoryID, nil
+}
+
+// MergeFunc reconciles an edit with a paste that changed since the edit was
+// prepared. It gets the edit that was refused and the paste as it is now, and
+// returns the edit to try next.
+type MergeFunc func(local EditPasteOptions, current *Paste) (EditPasteOptions, error)
+
+// EditPasteWithRetry tries to apply options on top of the expected revision.
+// Each time the paste turns out to have changed, merge is called to rebase the
+// edit on the current paste and the edit is tried again, up to maxAttempts
+// times in total. The last *ConflictError is returned if every attempt
+// conflicts, or straight away when merge is nil.
+func (c *Client) EditPasteWithRetry(ctx context.Context, pasteID string, expected Revision, options EditPasteOptions, merge MergeFunc, maxAttempts int, opts ...RequestOption) (*Paste, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultEditAttempts
+	}
+
+	var conflict *ConflictError
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		paste, err := c.EditPasteIfUnchanged(ctx, pasteID, expected, options, opts...)
+		if !errors.As(err, &conflict) || merge == nil {
+			return paste, err
+		}
+
+		options, err = merge(options, conflict.Current)
+		if err != nil {
+			return nil, fmt.Errorf("could not merge edit: %w", err)
+		}
+		expected = RevisionOf(conflict.Current)
+	}
+
+	return nil, conflict
+}
@@ -0,0 +1,510 @@
+package gopastemyst
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Sammie156/go-pastemyst/internal/fileutil"
+)
+
+// Defaults for OutboxOptions
+const (
+	DefaultOutboxMinBackoff = time.Second
+	DefaultOutboxMaxBackoff = 5 * time.Minute
+)
+
+type OutboxOp int
+
+const (
+	OutboxCreate OutboxOp = iota + 1
+	OutboxEdit
+	OutboxStar
+	OutboxPin
+)
+
+func (op OutboxOp) String() string {
+	switch op {
+	case OutboxCreate:
+		return "create"
+	case OutboxEdit:
+		return "edit"
+	case OutboxStar:
+		return "star"
+	case OutboxPin:
+		return "pin"
+	default:
+		return fmt.Sprintf("OutboxOp(%d)", int(op))
+	}
+}
+
+// OutboxResult is the outcome of a queued operation. Paste is nil for star
+// and pin operations, and when Err is set.
+type OutboxResult struct {
+	Seq     uint64
+	Op      OutboxOp
+	PasteID string
+	Paste   *Paste
+	Err     error
+}
+
+type OutboxOptions struct {
+	// Delay before retrying after a network failure, doubling on every
+	// failure in a row up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Called with the outcome of every queued operation, including those
+	// queued before the process restarted, which have no future
+	OnResult func(OutboxResult)
+
+	// Called when replaying an operation fails because of the network
+	OnRetry func(seq uint64, op OutboxOp, err error)
+}
+
+// Outbox sends paste creations, edits, stars and pins, queueing them on disk
+// when the network is unreachable. Create one with Client.NewOutbox and start
+// replaying queued operations with Run.
+//
+// Operations are sent in the order they were made: once something is
+// queued, later operations are queued behind it. Queued creations are given
+// an idempotency key when they have none, so they are safe to replay on a
+// client configured with WithIdempotency. Stars and pins toggle, so one
+// whose response was lost may be applied twice.
+//
+// With a client secret scanner, see WithSecretScanner, creations and edits
+// are checked before they can be queued, so blocked content never reaches
+// the disk and redacted content is stored redacted.
+type Outbox struct {
+	client *Client
+	dir    string
+	opts   OutboxOptions
+	wake   chan struct{}
+
+	// Held by submit from checking the queue until its operation is sent or
+	// queued, so operations made meanwhile can't overtake it
+	submitting chan struct{}
+
+	mu      sync.Mutex
+	queue   []*outboxEntry
+	futures map[uint64]*OutboxFuture
+	nextSeq uint64
+}
+
+// outboxEntry is a queued operation as stored on disk, one file per entry.
+type outboxEntry struct {
+	Seq      uint64              `json:"seq"`
+	Op       OutboxOp            `json:"op"`
+	PasteID  string              `json:"pasteID,omitempty"`
+	Create   *CreatePasteOptions `json:"create,omitempty"`
+	Edit     *EditPasteOptions   `json:"edit,omitempty"`
+	Request  storedRequest       `json:"request"`
+	QueuedAt time.Time           `json:"queuedAt"`
+}
+
+// storedRequest holds the RequestOptions of a queued operation, so it is
+// replayed the way it was asked for.
+type storedRequest struct {
+	Anonymous      bool          `json:"anonymous,omitempty"`
+	Timeout        time.Duration `json:"timeout,omitempty"`
+	Header         http.Header   `json:"header,omitempty"`
+	IdempotencyKey string        `json:"idempotencyKey,omitempty"`
+	NoDedup        bool          `json:"noDedup,omitempty"`
+	Scanned        bool          `json:"scanned,omitempty"`
+}
+
+func storeRequest(opts []RequestOption) storedRequest {
+	cfg := newRequestConfig(opts)
+	return storedRequest{
+		Anonymous:      cfg.anonymous,
+		Timeout:        cfg.timeout,
+		Header:         cfg.header,
+		IdempotencyKey: cfg.idempotencyKey,
+		NoDedup:        cfg.noDedup,
+		Scanned:        cfg.scanned,
+	}
+}
+
+func (r storedRequest) options() []RequestOption {
+	return []RequestOption{func(cfg *requestConfig) {
+		*cfg = requestConfig{
+			anonymous:      r.Anonymous,
+			timeout:        r.Timeout,
+			header:         r.Header,
+			idempotencyKey: r.IdempotencyKey,
+			noDedup:        r.NoDedup,
+			scanned:        r.Scanned,
+		}
+	}}
+}
+
+// OutboxFuture is the eventual outcome of an operation sent through an
+// Outbox.
+type OutboxFuture struct {
+	done   chan struct{}
+	queued bool
+	result OutboxResult
+}
+
+func newOutboxFuture() *OutboxFuture {
+	return &OutboxFuture{done: make(chan struct{})}
+}
+
+func (f *OutboxFuture) resolve(result OutboxResult) {
+	f.result = result
+	close(f.done)
+}
+
+// Done returns a channel closed once the operation has completed.
+func (f *OutboxFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Queued reports whether the operation was queued instead of sent right away.
+func (f *OutboxFuture) Queued() bool {
+	return f.queued
+}
+
+// Wait blocks until the operation has completed or ctx is done. The paste is
+// nil for star and pin operations.
+func (f *OutboxFuture) Wait(ctx context.Context) (*Paste, error) {
+	select {
+	case <-f.done:
+		return f.result.Paste, f.result.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// NewOutbox creates an Outbox keeping its queue in dir, creating it if
+// needed. Operations left in dir by a previous run are queued again.
+func (c *Client) NewOutbox(dir string, opts OutboxOptions) (*Outbox, error) {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultOutboxMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(DefaultOutboxMaxBackoff, opts.MinBackoff)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create outbox directory: %w", err)
+	}
+
+	o := &Outbox{
+		client:     c,
+		dir:        dir,
+		opts:       opts,
+		wake:       make(chan struct{}, 1),
+		submitting: make(chan struct{}, 1),
+		futures:    make(map[uint64]*OutboxFuture),
+		nextSeq:    1,
+	}
+
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// load reads the operations queued in the outbox directory.
+func (o *Outbox) load() error {
+	files, err := filepath.Glob(filepath.Join(o.dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("could not read outbox: %w", err)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("could not read outbox: %w", err)
+		}
+
+		var entry outboxEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("could not decode outbox entry %s: %w", filepath.Base(file), err)
+		}
+
+		o.queue = append(o.queue, &entry)
+		o.nextSeq = max(o.nextSeq, entry.Seq+1)
+	}
+
+	slices.SortFunc(o.queue, func(a, b *outboxEntry) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+
+	return nil
+}
+
+// Pending returns the number of queued operations.
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.queue)
+}
+
+// CreatePaste creates a paste, or queues its creation.
+func (o *Outbox) CreatePaste(ctx context.Context, options CreatePasteOptions, opts ...RequestOption) *OutboxFuture {
+	return o.submit(ctx, &outboxEntry{Op: OutboxCreate, Create: &options}, opts)
+}
+
+// EditPaste edits a paste, or queues the edit.
+func (o *Outbox) EditPaste(ctx context.Context, pasteID string, options EditPasteOptions, opts ...RequestOption) *OutboxFuture {
+	return o.submit(ctx, &outboxEntry{Op: OutboxEdit, PasteID: pasteID, Edit: &options}, opts)
+}
+
+// StarPaste stars or unstars a paste, or queues it.
+func (o *Outbox) StarPaste(ctx context.Context, pasteID string, opts ...RequestOption) *OutboxFuture {
+	return o.submit(ctx, &outboxEntry{Op: OutboxStar, PasteID: pasteID}, opts)
+}
+
+// PinPaste pins or unpins a paste, or queues it.
+func (o *Outbox) PinPaste(ctx context.Context, pasteID string, opts ...RequestOption) *OutboxFuture {
+	return o.submit(ctx, &outboxEntry{Op: OutboxPin, PasteID: pasteID}, opts)
+}
+
+// submit sends entry right away when nothing is queued, and queues it when
+// something is or when sending fails because of the network.
+func (o *Outbox) submit(ctx context.Context, entry *outboxEntry, opts []RequestOption) *OutboxFuture {
+	future := newOutboxFuture()
+
+	opts, err := o.scan(entry, opts)
+	if err != nil {
+		future.resolve(OutboxResult{Op: entry.Op, PasteID: entry.PasteID, Err: err})
+		return future
+	}
+
+	if entry.Op == OutboxCreate && newRequestConfig(opts).idempotencyKey == "" {
+		opts = appendOptions(opts, WithIdempotencyKey(NewIdempotencyKey()))
+	}
+	entry.Request = storeRequest(opts)
+
+	select {
+	case o.submitting <- struct{}{}:
+	case <-ctx.Done():
+		future.resolve(OutboxResult{Op: entry.Op, PasteID: entry.PasteID, Err: ctx.Err()})
+		return future
+	}
+	defer func() { <-o.submitting }()
+
+	o.mu.Lock()
+	queued := len(o.queue) > 0
+	o.mu.Unlock()
+
+	if !queued {
+		paste, err := o.send(ctx, entry)
+		if err == nil || !isNetworkError(ctx, err) {
+			future.resolve(OutboxResult{Op: entry.Op, PasteID: entry.PasteID, Paste: paste, Err: err})
+			return future
+		}
+	}
+
+	if err := o.enqueue(entry, future); err != nil {
+		future.resolve(OutboxResult{Op: entry.Op, PasteID: entry.PasteID, Err: err})
+	}
+
+	return future
+}
+
+// scan applies the client's secret scanner to the content of entry, leaving it
+// redacted if the scanner redacts, and returns opts marking it as scanned.
+func (o *Outbox) scan(entry *outboxEntry, opts []RequestOption) ([]RequestOption, error) {
+	scanner := o.client.scanner
+	if scanner == nil || newRequestConfig(opts).scanned {
+		return opts, nil
+	}
+
+	switch entry.Op {
+	case OutboxCreate:
+		options, err := scanner.checkPaste(*entry.Create)
+		if err != nil {
+			return nil, err
+		}
+		entry.Create = &options
+	case OutboxEdit:
+		options, err := scanner.checkEdit(*entry.Edit)
+		if err != nil {
+			return nil, err
+		}
+		entry.Edit = &options
+	default:
+		return opts, nil
+	}
+
+	return appendOptions(opts, withScanned()), nil
+}
+
+func (o *Outbox) enqueue(entry *outboxEntry, future *OutboxFuture) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry.Seq = o.nextSeq
+	entry.QueuedAt = time.Now()
+
+	if err := o.write(entry); err != nil {
+		return err
+	}
+
+	o.nextSeq++
+	o.queue = append(o.queue, entry)
+	o.futures[entry.Seq] = future
+	future.queued = true
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (o *Outbox) path(seq uint64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%020d.json", seq))
+}
+
+func (o *Outbox) write(entry *outboxEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not encode outbox entry: %w", err)
+	}
+
+	if err := fileutil.WriteFileAtomic(o.dir, o.path(entry.Seq), data); err != nil {
+		return fmt.Errorf("could not write outbox entry: %w", err)
+	}
+
+	return nil
+}
+
+// Run replays queued operations in order until ctx is cancelled, then returns
+// the context's error. An operation failing because of the network is
+// retried with backoff, blocking the ones after it. Any other failure is
+// reported as its result and the next operation is sent.
+func (o *Outbox) Run(ctx context.Context) error {
+	var backoff time.Duration
+
+	for {
+		entry := o.head()
+		if entry == nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-o.wake:
+			}
+			continue
+		}
+
+		paste, err := o.send(ctx, entry)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil && isNetworkError(ctx, err) {
+			if o.opts.OnRetry != nil {
+				o.opts.OnRetry(entry.Seq, entry.Op, err)
+			}
+
+			backoff = min(max(backoff*2, o.opts.MinBackoff), o.opts.MaxBackoff)
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			continue
+		}
+
+		backoff = 0
+		o.complete(OutboxResult{Seq: entry.Seq, Op: entry.Op, PasteID: entry.PasteID, Paste: paste, Err: err})
+	}
+}
+
+func (o *Outbox) head() *outboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.queue) == 0 {
+		return nil
+	}
+
+	return o.queue[0]
+}
+
+// complete removes the operation at the head of the queue and reports its
+// result.
+func (o *Outbox) complete(result OutboxResult) {
+	o.mu.Lock()
+	o.queue = o.queue[1:]
+	future := o.futures[result.Seq]
+	delete(o.futures, result.Seq)
+	o.mu.Unlock()
+
+	os.Remove(o.path(result.Seq))
+
+	if future != nil {
+		future.resolve(result)
+	}
+	if o.opts.OnResult != nil {
+		o.opts.OnResult(result)
+	}
+}
+
+func (o *Outbox) send(ctx context.Context, entry *outboxEntry) (*Paste, error) {
+	opts := entry.Request.options()
+
+	switch entry.Op {
+	case OutboxCreate:
+		return o.client.CreatePaste(ctx, *entry.Create, opts...)
+	case OutboxEdit:
+		return o.client.EditPaste(ctx, entry.PasteID, *entry.Edit, opts...)
+	case OutboxStar:
+		return nil, o.client.StarPaste(ctx, entry.PasteID, opts...)
+	case OutboxPin:
+		return nil, o.client.PinPaste(ctx, entry.PasteID, opts...)
+	default:
+		return nil, fmt.Errorf("unknown outbox operation %s", entry.Op)
+	}
+}
+
+// isNetworkError reports whether err means the API couldn't be reached, as
+// opposed to the API rejecting the request, the request being invalid or ctx
+// being done. An open circuit breaker counts as the API being unreachable.
+func isNetworkError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	// Failing to connect, which covers refused connections and failed DNS
+	// lookups. TLS failures are net.OpErrors too, but not dial ones, and
+	// retrying won't fix them.
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -14,87 +14,107 @@ import (
 
 // TODO: Add documentation to each struct type and function
 
-func (c *Client) GetPaste(ctx context.Context, pasteID string) (*Paste, error) {
+func (c *Client) GetPaste(ctx context.Context, pasteID string, opts ...RequestOption) (*Paste, error) {
 	url := fmt.Sprintf("%s/pastes/%s", c.baseURL, pasteID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-
+	body, err := c.getCached(ctx, url, opts)
 	if err != nil {
-		return nil, fmt.Errorf("could not create request: %w", err)
+		return nil, err
 	}
 
-	res, err := c.httpClient.Do(req)
-
-	if err != nil {
-		return nil, fmt.Errorf("http Request failed: %w", err)
+	var paste Paste
+	if err := json.Unmarshal(body, &paste); err != nil {
+		return nil, fmt.Errorf("could not decode JSON response: %w", err)
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
+	return &paste, nil
+}
 
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err == nil {
-			return nil, fmt.Errorf("API Error (%s): %s", res.Status, apiError.StatusMessage)
-		}
+// fetchPaste works like GetPaste but always asks the API, bypassing the
+// response cache. It is used where a stale paste would hide changes.
+func (c *Client) fetchPaste(ctx context.Context, pasteID string, opts []RequestOption) (*Paste, error) {
+	url := fmt.Sprintf("%s/pastes/%s", c.baseURL, pasteID)
 
-		bodyBytes, err := io.ReadAll(res.Body)
-		if err != nil {
-			return nil, fmt.Errorf("API returned non-200 status: %s, (body %s)",
-				res.Status, string(bodyBytes))
-		}
+	body, err := c.get(ctx, url, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	var paste Paste
-	if err := json.NewDecoder(res.Body).Decode(&paste); err != nil {
+	if err := json.Unmarshal(body, &paste); err != nil {
 		return nil, fmt.Errorf("could not decode JSON response: %w", err)
 	}
 
 	return &paste, nil
 }
 
-func (c *Client) GetPasteStats(ctx context.Context, pasteID string) (*Stats, error) {
+func (c *Client) GetPasteStats(ctx context.Context, pasteID string, opts ...RequestOption) (*Stats, error) {
 	url := fmt.Sprintf("%s/pastes/%s/stats", c.baseURL, pasteID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-
+	body, err := c.getCached(ctx, url, opts)
 	if err != nil {
-		return nil, fmt.Errorf("could not create request: %w", err)
+		return nil, err
 	}
 
-	res, err := c.httpClient.Do(req)
-
-	if err != nil {
-		return nil, fmt.Errorf("http request failed : %w", err)
+	var stats Stats
+	if err := json.Unmarshal(body, &stats); err != nil {
+		return nil, fmt.Errorf("could not decode JSON response: %w", err)
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
+	return &stats, nil
+}
 
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err == nil {
-			return nil, fmt.Errorf("API Error (%s): %s", res.Status, apiError.StatusMessage)
-		}
+// fetchPasteStats works like GetPasteStats but always asks the API, bypassing
+// the response cache.
+func (c *Client) fetchPasteStats(ctx context.Context, pasteID string, opts []RequestOption) (*Stats, error) {
+	url := fmt.Sprintf("%s/pastes/%s/stats", c.baseURL, pasteID)
 
-		bodyBytes, err := io.ReadAll(res.Body)
-		if err != nil {
-			return nil, fmt.Errorf("API returned non-200 status: %s, (body %s)",
-				res.Status, string(bodyBytes))
-		}
+	body, err := c.get(ctx, url, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	var stats Stats
-	if err := json.NewDecoder(res.Body).Decode(&stats); err != nil {
+	if err := json.Unmarshal(body, &stats); err != nil {
 		return nil, fmt.Errorf("could not decode JSON response: %w", err)
 	}
 
 	return &stats, nil
 }
 
-func (c *Client) CreatePaste(ctx context.Context, options CreatePasteOptions) (*Paste, error) {
+func (c *Client) CreatePaste(ctx context.Context, options CreatePasteOptions, opts ...RequestOption) (*Paste, error) {
 	if len(options.Pasties) == 0 {
 		return nil, fmt.Errorf("at least one pasty should be present")
 	}
 
+	cfg := newRequestConfig(opts)
+	if c.scanner != nil && !cfg.scanned {
+		var err error
+		if options, err = c.scanner.checkPaste(options); err != nil {
+			return nil, err
+		}
+	}
+
+	create := func() (*Paste, error) {
+		return c.createPaste(ctx, options, opts)
+	}
+
+	if c.dedup != nil && !cfg.noDedup {
+		send := create
+		create = func() (*Paste, error) {
+			return c.createDeduped(ctx, options, opts, send)
+		}
+	}
+
+	if cfg.idempotencyKey != "" && c.idempotency != nil {
+		return c.createIdempotent(ctx, cfg.idempotencyKey, options, opts, create)
+	}
+
+	return create()
+}
+
+// createPaste sends options, already checked by CreatePaste, to the API.
+func (c *Client) createPaste(ctx context.Context, options CreatePasteOptions, opts []RequestOption) (*Paste, error) {
 	// Converting the struct into JSON data
 	jsonData, err := json.Marshal(options)
 	if err != nil {
@@ -104,29 +124,22 @@ func (c *Client) CreatePaste(ctx context.Context, options CreatePasteOptions) (*
 	// Creating the request
 	url := fmt.Sprintf("%s/pastes", c.baseURL)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	req, err := c.newRequest(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData), false, opts)
 	if err != nil {
-		return nil, fmt.Errorf("could not create request: %w", err)
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.apiToken != "" {
-		req.Header.Set("Authorization", "Bearer "+c.apiToken)
-	}
 
 	// Executing the request
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req, opts)
 	if err != nil {
 		return nil, fmt.Errorf("http request failed: %w", err)
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusCreated {
-		var apiError APIError
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err != nil {
-			return nil, fmt.Errorf("api error (%s): %s", res.Status, apiError.StatusMessage)
-		}
-		return nil, fmt.Errorf("api returned non-201 status: %s", res.Status)
+	if err := checkResponse(res, http.StatusCreated); err != nil {
+		return nil, err
 	}
 
 	var newPaste Paste
@@ -137,218 +150,89 @@ func (c *Client) CreatePaste(ctx context.Context, options CreatePasteOptions) (*
 	return &newPaste, nil
 }
 
-func (c *Client) GetPasteLanguageStats(ctx context.Context, pasteID string) ([]PasteLanguageStats, error) {
+func (c *Client) GetPasteLanguageStats(ctx context.Context, pasteID string, opts ...RequestOption) ([]PasteLanguageStats, error) {
 	url := fmt.Sprintf("%s/pastes/%s/langs", c.baseURL, pasteID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	body, err := c.getCached(ctx, url, opts)
 	if err != nil {
-		return nil, fmt.Errorf("could not create request: %w", err)
-	}
-
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("http request failed: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
-
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err == nil {
-			return nil, fmt.Errorf("API Error (%s) : %s", res.Status, apiError.StatusMessage)
-		}
-
-		bodyBytes, err := io.ReadAll(res.Body)
-		if err != nil {
-			return nil, fmt.Errorf("API returned non-200 status: %s, (body %s)",
-				res.Status, string(bodyBytes))
-		}
+		return nil, err
 	}
 
 	var pasteLangStats []PasteLanguageStats
 
-	if err := json.NewDecoder(res.Body).Decode(&pasteLangStats); err != nil {
+	if err := json.Unmarshal(body, &pasteLangStats); err != nil {
 		return nil, fmt.Errorf("could not decode JSON response: %w", err)
 	}
 
 	return pasteLangStats, nil
 }
 
-func (c *Client) GetCompactPasteHistory(ctx context.Context, pasteID string) ([]CompactPasteHistory, error) {
+func (c *Client) GetCompactPasteHistory(ctx context.Context, pasteID string, opts ...RequestOption) ([]CompactPasteHistory, error) {
 	url := fmt.Sprintf("%s/pastes/%s/history_compact", c.baseURL, pasteID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	body, err := c.get(ctx, url, opts)
 	if err != nil {
-		return nil, fmt.Errorf("could not create request: %w", err)
-	}
-
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("http request failed: %w", err)
-	}
-
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
-
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err == nil {
-			return nil, fmt.Errorf("API Error (%s) : %s", res.Status, apiError.StatusMessage)
-		}
-
-		bodyBytes, err := io.ReadAll(res.Body)
-		if err != nil {
-			return nil, fmt.Errorf("API returned non-200 status: %s, (body %s)",
-				res.Status, string(bodyBytes))
-		}
+		return nil, err
 	}
 
 	var compactPasteHistory []CompactPasteHistory
-	if err := json.NewDecoder(res.Body).Decode(&compactPasteHistory); err != nil {
+	if err := json.Unmarshal(body, &compactPasteHistory); err != nil {
 		return nil, fmt.Errorf("could not decode JSON response: %w", err)
 	}
 
 	return compactPasteHistory, nil
 }
 
-func (c *Client) GetPasteAtSpecificEdit(ctx context.Context, pasteID string, historyID string) (*Paste, error) {
+func (c *Client) GetPasteAtSpecificEdit(ctx context.Context, pasteID string, historyID string, opts ...RequestOption) (*Paste, error) {
 	url := fmt.Sprintf("%s/pastes/%s/history/%s", c.baseURL, pasteID, historyID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	body, err := c.get(ctx, url, opts)
 	if err != nil {
-		return nil, fmt.Errorf("could not create request: %w", err)
-	}
-
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("http request failed: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
-
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err == nil {
-			return nil, fmt.Errorf("API Error (%s) : %s", res.Status, apiError.StatusMessage)
-		}
-
-		bodyBytes, err := io.ReadAll(res.Body)
-		if err != nil {
-			return nil, fmt.Errorf("API returned non-200 status: %s, (body %s)",
-				res.Status, string(bodyBytes))
-		}
+		return nil, err
 	}
 
 	var paste Paste
 
-	if err := json.NewDecoder(res.Body).Decode(&paste); err != nil {
+	if err := json.Unmarshal(body, &paste); err != nil {
 		return nil, fmt.Errorf("could not decode JSON: %w", err)
 	}
 
 	return &paste, nil
 }
 
-func (c *Client) GetDiffAtCertainEdit(ctx context.Context, pasteID string, historyID string) (*PasteDiff, error) {
+func (c *Client) GetDiffAtCertainEdit(ctx context.Context, pasteID string, historyID string, opts ...RequestOption) (*PasteDiff, error) {
 	url := fmt.Sprintf("%s/pastes/%s/history/%s/diff", c.baseURL, pasteID, historyID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("could not create http request: %w", err)
-	}
-
-	res, err := c.httpClient.Do(req)
+	body, err := c.get(ctx, url, opts)
 	if err != nil {
-		return nil, fmt.Errorf("http request failed: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
-
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err == nil {
-			return nil, fmt.Errorf("API Error (%s) : %s", res.Status, apiError.StatusMessage)
-		}
-
-		bodyBytes, err := io.ReadAll(res.Body)
-		if err != nil {
-			return nil, fmt.Errorf("API returned non-200 status: %s, (body %s)",
-				res.Status, string(bodyBytes))
-		}
+		return nil, err
 	}
 
 	var pasteDiff PasteDiff
-	if err := json.NewDecoder(res.Body).Decode(&pasteDiff); err != nil {
+	if err := json.Unmarshal(body, &pasteDiff); err != nil {
 		return nil, fmt.Errorf("could not decode JSON: %w", err)
 	}
 
 	return &pasteDiff, nil
 }
 
-func (c *Client) DownloadPasteAsZip(ctx context.Context, pasteID string) ([]byte, error) {
+func (c *Client) DownloadPasteAsZip(ctx context.Context, pasteID string, opts ...RequestOption) ([]byte, error) {
 	url := fmt.Sprintf("%s/pastes/%s.zip", c.baseURL, pasteID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	zipData, err := c.get(ctx, url, opts)
 	if err != nil {
-		return nil, fmt.Errorf("could not create http request: %w", err)
-	}
-
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("http request failed: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
-
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err == nil {
-			return nil, fmt.Errorf("API Error (%s) : %s", res.Status, apiError.StatusMessage)
-		}
-
-		bodyBytes, err := io.ReadAll(res.Body)
-		if err != nil {
-			return nil, fmt.Errorf("API returned non-200 status: %s, (body %s)",
-				res.Status, string(bodyBytes))
-		}
-	}
-
-	zipData, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
 
 	return zipData, nil
 }
 
-func (c *Client) IsPasteEncrypted(ctx context.Context, pasteID string) (bool, error) {
+func (c *Client) IsPasteEncrypted(ctx context.Context, pasteID string, opts ...RequestOption) (bool, error) {
 	url := fmt.Sprintf("%s/pastes/%s/encrypted", c.baseURL, pasteID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	bodyBytes, err := c.get(ctx, url, opts)
 	if err != nil {
-		return false, fmt.Errorf("could not create http request: %w", err)
-	}
-
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		return false, fmt.Errorf("http request failed: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
-
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err == nil {
-			return false, fmt.Errorf("API Error (%s) : %s", res.Status, apiError.StatusMessage)
-		}
-
-		bodyBytes, err := io.ReadAll(res.Body)
-		if err != nil {
-			return false, fmt.Errorf("API returned non-200 status: %s, (body %s)",
-				res.Status, string(bodyBytes))
-		}
-	}
-
-	bodyBytes, err := io.ReadAll(res.Body)
-	if err != nil {
-		return false, fmt.Errorf("failed to read response body: %w", err)
+		return false, err
 	}
 
 	isEncrypted, err := strconv.ParseBool(string(bodyBytes))
@@ -359,33 +243,22 @@ func (c *Client) IsPasteEncrypted(ctx context.Context, pasteID string) (bool, er
 	return isEncrypted, nil
 }
 
-func (c *Client) IsPasteStarred(ctx context.Context, pasteID string) (bool, error) {
+func (c *Client) IsPasteStarred(ctx context.Context, pasteID string, opts ...RequestOption) (bool, error) {
 	url := fmt.Sprintf("%s/pastes/%s/star", c.baseURL, pasteID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := c.newRequest(ctx, http.MethodGet, url, nil, true, opts)
 	if err != nil {
-		return false, fmt.Errorf("could not create http request: %w", err)
+		return false, err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.apiToken)
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req, opts)
 	if err != nil {
 		return false, fmt.Errorf("http request failed: %w", err)
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
-
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err == nil {
-			return false, fmt.Errorf("API Error (%s) : %s", res.Status, apiError.StatusMessage)
-		}
-
-		bodyBytes, err := io.ReadAll(res.Body)
-		if err != nil {
-			return false, fmt.Errorf("API returned non-200 status: %s, (body %s)",
-				res.Status, string(bodyBytes))
-		}
+	if err := checkResponse(res, http.StatusOK); err != nil {
+		return false, err
 	}
 
 	bodyBytes, err := io.ReadAll(res.Body)
@@ -394,7 +267,6 @@ func (c *Client) IsPasteStarred(ctx context.Context, pasteID string) (bool, erro
 	}
 
 	isStarred, err := strconv.ParseBool(string(bodyBytes))
-	fmt.Printf("Body Bytes: %s\n", string(bodyBytes))
 	if err != nil {
 		return false, fmt.Errorf("api returned non-boolean value: %s", string(bodyBytes))
 	}
@@ -404,101 +276,83 @@ func (c *Client) IsPasteStarred(ctx context.Context, pasteID string) (bool, erro
 
 // TODO: Get back to this and finish all Paste endpoints
 
-func (c *Client) StarPaste(ctx context.Context, pasteID string) error {
+func (c *Client) StarPaste(ctx context.Context, pasteID string, opts ...RequestOption) error {
 	url := fmt.Sprintf("%s/pastes/%s/star", c.baseURL, pasteID)
 
-	if c.apiToken == "" {
-		return fmt.Errorf("please provide API token")
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBufferString(""))
+	req, err := c.newRequest(ctx, http.MethodPost, url, nil, true, opts)
 	if err != nil {
-		return fmt.Errorf("could not create request: %w", err)
+		return err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiToken)
-
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req, opts)
 	if err != nil {
 		return fmt.Errorf("http request failed: %w", err)
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err != nil {
-			return fmt.Errorf("api error (%s): %s", res.Status, apiError.StatusMessage)
-		}
-
-		return fmt.Errorf("api returned non-204 status : %s", res.Status)
+	if err := checkResponse(res, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
 	}
 
+	c.invalidatePaste(ctx, pasteID)
+
 	return nil
 }
 
-func (c *Client) PinPaste(ctx context.Context, pasteID string) error {
+func (c *Client) PinPaste(ctx context.Context, pasteID string, opts ...RequestOption) error {
 	url := fmt.Sprintf("%s/pastes/%s/pin", c.baseURL, pasteID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBufferString(""))
+	req, err := c.newRequest(ctx, http.MethodPost, url, nil, true, opts)
 	if err != nil {
-		return fmt.Errorf("could not create http request: %w", err)
+		return err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiToken)
-
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req, opts)
 	if err != nil {
 		return fmt.Errorf("http request failed: %w", err)
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
-
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err != nil {
-			return fmt.Errorf("api error (%s) : %s", res.Status, apiError.StatusMessage)
-		}
-
-		return fmt.Errorf("api error (%s): %s", res.Status, apiError.StatusMessage)
+	if err := checkResponse(res, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
 	}
 
+	c.invalidatePaste(ctx, pasteID)
+
 	return nil
 }
 
-func (c *Client) PrivatePaste(ctx context.Context, pasteID string) error {
+func (c *Client) PrivatePaste(ctx context.Context, pasteID string, opts ...RequestOption) error {
 	url := fmt.Sprintf("%s/pastes/%s/private", c.baseURL, pasteID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBufferString(""))
+	req, err := c.newRequest(ctx, http.MethodPost, url, nil, true, opts)
 	if err != nil {
-		return fmt.Errorf("could not create http request: %w", err)
+		return err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiToken)
-
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req, opts)
 	if err != nil {
 		return fmt.Errorf("http request failed: %w", err)
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
-
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err != nil {
-			return fmt.Errorf("api error (%s) : %s", res.Status, apiError.StatusMessage)
-		}
-
-		return fmt.Errorf("api error (%s): %s", res.Status, apiError.StatusMessage)
+	if err := checkResponse(res, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
 	}
 
+	c.invalidatePaste(ctx, pasteID)
+
 	return nil
 }
 
-func (c *Client) EditPaste(ctx context.Context, pasteID string, options EditPasteOptions) (*Paste, error) {
+func (c *Client) EditPaste(ctx context.Context, pasteID string, options EditPasteOptions, opts ...RequestOption) (*Paste, error) {
 	url := fmt.Sprintf("%s/pastes/%s", c.baseURL, pasteID)
 
-	if c.apiToken == "" {
-		return nil, fmt.Errorf("please provide API token")
+	if c.scanner != nil && !newRequestConfig(opts).scanned {
+		var err error
+		if options, err = c.scanner.checkEdit(options); err != nil {
+			return nil, err
+		}
 	}
 
 	jsonData, err := json.Marshal(options)
@@ -506,30 +360,25 @@ func (c *Client) EditPaste(ctx context.Context, pasteID string, options EditPast
 		return nil, fmt.Errorf("could not marshal edit options: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, bytes.NewBuffer(jsonData))
+	req, err := c.newRequest(ctx, http.MethodPatch, url, bytes.NewBuffer(jsonData), true, opts)
 	if err != nil {
-		return nil, fmt.Errorf("could not create http request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiToken)
 	req.Header.Set("Content-Type", "application/json")
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req, opts)
 	if err != nil {
-		return nil, fmt.Errorf("http request failed")
+		return nil, fmt.Errorf("http request failed: %w", err)
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
-
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err != nil {
-			return nil, fmt.Errorf("API error (%s): %s", res.Status, apiError.StatusMessage)
-		}
-
-		return nil, fmt.Errorf("API error (%s): %s", res.Status, apiError.StatusMessage)
+	if err := checkResponse(res, http.StatusOK); err != nil {
+		return nil, err
 	}
 
+	c.invalidatePaste(ctx, pasteID)
+
 	var paste Paste
 	if err := json.NewDecoder(res.Body).Decode(&paste); err != nil {
 		return nil, fmt.Errorf("could not decode json: %w", err)
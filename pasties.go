@@ -0,0 +1,141 @@
+package gopastemyst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrPastyNotFound is returned when no pasty of a paste matches the given ID
+// or title.
+var ErrPastyNotFound = errors.New("pasty not found")
+
+// AddPasty appends a pasty to an existing paste.
+func (c *Client) AddPasty(ctx context.Context, pasteID string, pasty CreatePastyOptions, opts ...RequestOption) (*Paste, error) {
+	return c.modifyPasties(ctx, pasteID, opts, func(pasties []EditPastyOptions) ([]EditPastyOptions, error) {
+		return append(pasties, EditPastyOptions{
+			Title:    pasty.Title,
+			Content:  pasty.Content,
+			Language: pasty.Language,
+		}), nil
+	})
+}
+
+// RemovePasty removes the pasty matching idOrTitle from a paste. Pastes need
+// at least one pasty, so the last one can't be removed.
+func (c *Client) RemovePasty(ctx context.Context, pasteID string, idOrTitle string, opts ...RequestOption) (*Paste, error) {
+	return c.modifyPasties(ctx, pasteID, opts, func(pasties []EditPastyOptions) ([]EditPastyOptions, error) {
+		index, err := findPasty(pasties, idOrTitle)
+		if err != nil {
+			return nil, err
+		}
+		if len(pasties) == 1 {
+			return nil, fmt.Errorf("can't remove the only pasty of paste %s", pasteID)
+		}
+
+		return append(pasties[:index], pasties[index+1:]...), nil
+	})
+}
+
+// UpdatePasty changes the pasty matching idOrTitle. Empty fields of update
+// are left as they are, and its ID is ignored.
+func (c *Client) UpdatePasty(ctx context.Context, pasteID string, idOrTitle string, update EditPastyOptions, opts ...RequestOption) (*Paste, error) {
+	return c.modifyPasties(ctx, pasteID, opts, func(pasties []EditPastyOptions) ([]EditPastyOptions, error) {
+		index, err := findPasty(pasties, idOrTitle)
+		if err != nil {
+			return nil, err
+		}
+
+		if update.Title != "" {
+			pasties[index].Title = update.Title
+		}
+		if update.Content != "" {
+			pasties[index].Content = update.Content
+		}
+		if update.Language != "" {
+			pasties[index].Language = update.Language
+		}
+
+		return pasties, nil
+	})
+}
+
+// RenamePasty sets the title of the pasty matching idOrTitle.
+func (c *Client) RenamePasty(ctx context.Context, pasteID string, idOrTitle string, title string, opts ...RequestOption) (*Paste, error) {
+	return c.UpdatePasty(ctx, pasteID, idOrTitle, EditPastyOptions{Title: title}, opts...)
+}
+
+// modifyPasties fetches a paste, lets change rework its pasties and sends the
+// result with modifyPaste.
+func (c *Client) modifyPasties(ctx context.Context, pasteID string, opts []RequestOption, change func([]EditPastyOptions) ([]EditPastyOptions, error)) (*Paste, error) {
+	return c.modifyPaste(ctx, pasteID, opts, func(_ *Paste, options *EditPasteOptions) error {
+		pasties, err := change(options.Pasties)
+		if err != nil {
+			return err
+		}
+
+		options.Pasties = pasties
+		return nil
+	})
+}
+
+// modifyPaste fetches a paste, turns it into an edit keeping everything as
+// is, lets change adjust that edit and sends it with EditPasteIfUnchanged. If
+// the paste is edited by someone else in between, change is applied again on
+// top of the new state, so concurrent edits are never lost.
+func (c *Client) modifyPaste(ctx context.Context, pasteID string, opts []RequestOption, change func(current *Paste, options *EditPasteOptions) error) (*Paste, error) {
+	current, err := c.fetchPaste(ctx, pasteID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	apply := func(_ EditPasteOptions, current *Paste) (EditPasteOptions, error) {
+		options := EditPasteOptions{
+			Title:   current.Title,
+			Pasties: make([]EditPastyOptions, 0, len(current.Pasties)+1),
+		}
+		for _, pasty := range current.Pasties {
+			options.Pasties = append(options.Pasties, editPastyFrom(pasty))
+		}
+
+		if err := change(current, &options); err != nil {
+			return EditPasteOptions{}, err
+		}
+
+		return options, nil
+	}
+
+	options, err := apply(EditPasteOptions{}, current)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.EditPasteWithRetry(ctx, pasteID, RevisionOf(current), options, apply, DefaultEditAttempts, opts...)
+}
+
+// findPasty returns the index of the pasty whose ID is idOrTitle or, failing
+// that, of the only pasty titled idOrTitle.
+func findPasty(pasties []EditPastyOptions, idOrTitle string) (int, error) {
+	for i, pasty := range pasties {
+		if pasty.ID == idOrTitle {
+			return i, nil
+		}
+	}
+
+	found := -1
+	for i, pasty := range pasties {
+		if pasty.Title != idOrTitle {
+			continue
+		}
+		if found >= 0 {
+			return 0, fmt.Errorf("more than one pasty is titled %q, use its ID instead", idOrTitle)
+		}
+		found = i
+	}
+
+	if found < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrPastyNotFound, idOrTitle)
+	}
+
+	return found, nil
+}
@@ -0,0 +1,397 @@
+package gopastemyst
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// DefaultMarkdownTemplate is used by RenderMarkdown unless
+// RenderOptions.MarkdownTemplate is set. Templates are executed with a
+// RenderData value.
+const DefaultMarkdownTemplate = `# {{if .Paste.Title}}{{.Paste.Title}}{{else}}Untitled paste{{end}}
+
+- ID: ` + "`{{.Paste.ID}}`" + `
+- Created: {{.CreatedAt}}
+{{- if .EditedAt}}
+- Edited: {{.EditedAt}}
+{{- end}}
+{{- if .DeletesAt}}
+- Expires: {{.DeletesAt}}
+{{- end}}
+{{- if .Paste.Tags}}
+- Tags: {{join .Paste.Tags ", "}}
+{{- end}}
+{{- if .Stats}}
+
+| Pasty | Language | Lines | Words | Bytes |
+| --- | --- | ---: | ---: | ---: |
+{{- range .Pasties}}
+| {{.Title}} | {{.Language}} | {{with .Stats}}{{.Lines}} | {{.Words}} | {{.Bytes}}{{else}} | | {{end}} |
+{{- end}}
+| **Total** | | {{.Stats.Lines}} | {{.Stats.Words}} | {{.Stats.Bytes}} |
+{{- end}}
+{{- if .Languages}}
+
+| Language | Share |
+| --- | ---: |
+{{- range .Languages}}
+| {{.Name}} | {{printf "%.1f" .Percentage}}% |
+{{- end}}
+{{- end}}
+{{range .Pasties}}
+## {{.Title}}
+
+{{.Fence}}{{.FenceLanguage}}
+{{.Content}}
+{{.Fence}}
+{{end}}`
+
+// DefaultHTMLTemplate is used by RenderHTML unless RenderOptions.HTMLTemplate
+// is set. Templates are executed with a RenderData value.
+const DefaultHTMLTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{if .Paste.Title}}{{.Paste.Title}}{{else}}Untitled paste{{end}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; }
+pre { background: #f6f8fa; padding: 1rem; overflow-x: auto; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ddd; padding: 0.25rem 0.5rem; text-align: left; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{if .Paste.Title}}{{.Paste.Title}}{{else}}Untitled paste{{end}}</h1>
+<dl>
+<dt>ID</dt><dd><code>{{.Paste.ID}}</code></dd>
+<dt>Created</dt><dd>{{.CreatedAt}}</dd>
+{{- if .EditedAt}}
+<dt>Edited</dt><dd>{{.EditedAt}}</dd>
+{{- end}}
+{{- if .DeletesAt}}
+<dt>Expires</dt><dd>{{.DeletesAt}}</dd>
+{{- end}}
+{{- if .Paste.Tags}}
+<dt>Tags</dt><dd>{{join .Paste.Tags ", "}}</dd>
+{{- end}}
+</dl>
+{{- if .Stats}}
+<table>
+<tr><th>Pasty</th><th>Language</th><th>Lines</th><th>Words</th><th>Bytes</th></tr>
+{{- range .Pasties}}
+<tr><td>{{.Title}}</td><td>{{.Language}}</td>{{with .Stats}}<td>{{.Lines}}</td><td>{{.Words}}</td><td>{{.Bytes}}</td>{{else}}<td></td><td></td><td></td>{{end}}</tr>
+{{- end}}
+<tr><th>Total</th><td></td><td>{{.Stats.Lines}}</td><td>{{.Stats.Words}}</td><td>{{.Stats.Bytes}}</td></tr>
+</table>
+{{- end}}
+{{- if .Languages}}
+<table>
+<tr><th>Language</th><th>Share</th></tr>
+{{- range .Languages}}
+<tr><td>{{.Name}}</td><td>{{printf "%.1f" .Percentage}}%</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</header>
+{{- range .Pasties}}
+<section>
+<h2>{{.Title}}</h2>
+<pre><code{{if .FenceLanguage}} class="language-{{.FenceLanguage}}"{{end}}>{{.Content}}</code></pre>
+</section>
+{{- end}}
+</body>
+</html>
+`
+
+type RenderOptions struct {
+	// Optional summary data, shown as tables when set
+	Stats     *Stats
+	Languages []PasteLanguageStats
+
+	// Replace the default templates. Both are executed with a RenderData
+	// value and have a "join" function (strings.Join) available.
+	MarkdownTemplate *texttemplate.Template
+	HTMLTemplate     *htmltemplate.Template
+}
+
+// RenderData is the value templates are executed with.
+type RenderData struct {
+	Paste     Paste
+	CreatedAt string
+	EditedAt  string
+	DeletesAt string
+	Pasties   []RenderPasty
+	Stats     *Stats
+	Languages []RenderLanguage
+}
+
+type RenderPasty struct {
+	ID       string
+	Title    string
+	Language string
+	Content  string
+	Stats    *PastyStats
+
+	// Code fence long enough to not clash with backticks in Content, and the
+	// info string to put after it
+	Fence         string
+	FenceLanguage string
+}
+
+type RenderLanguage struct {
+	Name       string
+	Percentage float64
+}
+
+var renderFuncs = map[string]any{
+	"join": strings.Join,
+}
+
+var (
+	defaultMarkdownTemplate = texttemplate.Must(texttemplate.New("markdown").Funcs(renderFuncs).Parse(DefaultMarkdownTemplate))
+	defaultHTMLTemplate     = htmltemplate.Must(htmltemplate.New("html").Funcs(renderFuncs).Parse(DefaultHTMLTemplate))
+)
+
+// NewMarkdownTemplate parses text as a Markdown template with the functions
+// the default template uses.
+func NewMarkdownTemplate(text string) (*texttemplate.Template, error) {
+	return texttemplate.New("markdown").Funcs(renderFuncs).Parse(text)
+}
+
+// NewHTMLTemplate parses text as an HTML template with the functions the
+// default template uses.
+func NewHTMLTemplate(text string) (*htmltemplate.Template, error) {
+	return htmltemplate.New("html").Funcs(renderFuncs).Parse(text)
+}
+
+// RenderMarkdown writes paste as a Markdown document with one fenced code
+// block per pasty.
+func RenderMarkdown(w io.Writer, paste Paste, options RenderOptions) error {
+	tmpl := options.MarkdownTemplate
+	if tmpl == nil {
+		tmpl = defaultMarkdownTemplate
+	}
+
+	if err := tmpl.Execute(w, newRenderData(paste, options)); err != nil {
+		return fmt.Errorf("could not render markdown: %w", err)
+	}
+
+	return nil
+}
+
+// RenderHTML writes paste as a standalone HTML page. Content is escaped by
+// html/template.
+func RenderHTML(w io.Writer, paste Paste, options RenderOptions) error {
+	tmpl := options.HTMLTemplate
+	if tmpl == nil {
+		tmpl = defaultHTMLTemplate
+	}
+
+	if err := tmpl.Execute(w, newRenderData(paste, options)); err != nil {
+		return fmt.Errorf("could not render html: %w", err)
+	}
+
+	return nil
+}
+
+// normalizedPaste is the layout written by RenderJSON. Field order is fixed
+// by the struct, and empty lists are always present.
+type normalizedPaste struct {
+	ID        string                `json:"id"`
+	Title     string                `json:"title"`
+	OwnerID   *string               `json:"ownerId"`
+	CreatedAt string                `json:"createdAt"`
+	EditedAt  *string               `json:"editedAt"`
+	DeletesAt *string               `json:"deletesAt"`
+	ExpiresIn string                `json:"expiresIn"`
+	Private   bool                  `json:"private"`
+	Pinned    bool                  `json:"pinned"`
+	Stars     int                   `json:"stars"`
+	Tags      []string              `json:"tags"`
+	Pasties   []normalizedPasty     `json:"pasties"`
+	Stats     *normalizedStats      `json:"stats,omitempty"`
+	Languages []normalizedLangShare `json:"languages,omitempty"`
+}
+
+type normalizedPasty struct {
+	ID       string      `json:"id"`
+	Title    string      `json:"title"`
+	Language string      `json:"language"`
+	Content  string      `json:"content"`
+	Stats    *PastyStats `json:"stats,omitempty"`
+}
+
+type normalizedStats struct {
+	Bytes int `json:"bytes"`
+	Lines int `json:"lines"`
+	Words int `json:"words"`
+}
+
+type normalizedLangShare struct {
+	Name       string  `json:"name"`
+	Percentage float64 `json:"percentage"`
+}
+
+// RenderJSON writes paste as indented JSON in a stable form: times are UTC
+// RFC 3339, tags are sorted, line endings are normalized to \n and
+// languages are ordered by share. Rendering the same paste twice always
+// gives identical output.
+func RenderJSON(w io.Writer, paste Paste, options RenderOptions) error {
+	data := newRenderData(paste, options)
+
+	out := normalizedPaste{
+		ID:        paste.ID,
+		Title:     paste.Title,
+		OwnerID:   paste.OwnerID,
+		CreatedAt: formatRenderTime(paste.CreatedAt),
+		EditedAt:  optionalRenderTime(paste.EditedAt),
+		DeletesAt: optionalRenderTime(paste.DeletesAt),
+		ExpiresIn: paste.ExpiresIn,
+		Private:   paste.Private,
+		Pinned:    paste.Pinned,
+		Stars:     paste.Stars,
+		Tags:      append([]string{}, paste.Tags...),
+		Pasties:   []normalizedPasty{},
+	}
+	sort.Strings(out.Tags)
+
+	for i, pasty := range data.Pasties {
+		out.Pasties = append(out.Pasties, normalizedPasty{
+			ID:       pasty.ID,
+			Title:    paste.Pasties[i].Title,
+			Language: pasty.Language,
+			Content:  pasty.Content,
+			Stats:    pasty.Stats,
+		})
+	}
+
+	if options.Stats != nil {
+		out.Stats = &normalizedStats{
+			Bytes: options.Stats.Bytes,
+			Lines: options.Stats.Lines,
+			Words: options.Stats.Words,
+		}
+	}
+
+	for _, language := range data.Languages {
+		out.Languages = append(out.Languages, normalizedLangShare(language))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		return fmt.Errorf("could not render json: %w", err)
+	}
+
+	return nil
+}
+
+func newRenderData(paste Paste, options RenderOptions) RenderData {
+	data := RenderData{
+		Paste:     paste,
+		CreatedAt: formatRenderTime(paste.CreatedAt),
+		Stats:     options.Stats,
+	}
+
+	if paste.EditedAt != nil {
+		data.EditedAt = formatRenderTime(*paste.EditedAt)
+	}
+	if paste.DeletesAt != nil {
+		data.DeletesAt = formatRenderTime(*paste.DeletesAt)
+	}
+
+	for _, pasty := range paste.Pasties {
+		content := strings.ReplaceAll(pasty.Content, "\r\n", "\n")
+
+		rendered := RenderPasty{
+			ID:            pasty.ID,
+			Title:         pasty.Title,
+			Language:      pasty.Language,
+			Content:       content,
+			Fence:         markdownFence(content),
+			FenceLanguage: fenceLanguage(pasty.Language),
+		}
+		if rendered.Title == "" {
+			rendered.Title = "Untitled"
+		}
+
+		if options.Stats != nil {
+			if stats, ok := options.Stats.Pasties[pasty.ID]; ok {
+				rendered.Stats = &stats
+			}
+		}
+
+		data.Pasties = append(data.Pasties, rendered)
+	}
+
+	for _, language := range options.Languages {
+		data.Languages = append(data.Languages, RenderLanguage{
+			Name:       language.Language.Name,
+			Percentage: language.Percentage,
+		})
+	}
+	sort.SliceStable(data.Languages, func(i, j int) bool {
+		if data.Languages[i].Percentage != data.Languages[j].Percentage {
+			return data.Languages[i].Percentage > data.Languages[j].Percentage
+		}
+		return data.Languages[i].Name < data.Languages[j].Name
+	})
+
+	return data
+}
+
+func formatRenderTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func optionalRenderTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+
+	formatted := formatRenderTime(*t)
+	return &formatted
+}
+
+// markdownFence returns a backtick fence one longer than the longest run of
+// backticks in content, and at least three long.
+func markdownFence(content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// Info strings for languages whose lowercased name isn't what Markdown
+// highlighters expect
+var fenceLanguages = map[string]string{
+	"c#":          "csharp",
+	"c++":         "cpp",
+	"f#":          "fsharp",
+	"objective-c": "objectivec",
+	"text":        "",
+	"autodetect":  "",
+}
+
+func fenceLanguage(language string) string {
+	name := strings.ToLower(strings.TrimSpace(language))
+	if mapped, ok := fenceLanguages[name]; ok {
+		return mapped
+	}
+
+	return strings.ReplaceAll(name, " ", "-")
+}
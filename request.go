@@ -0,0 +1,200 @@
+package gopastemyst
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// RequestOption changes how a single call is made, without affecting the
+// rest of the client. For calls made of several requests, options apply to
+// each of them.
+type RequestOption func(*requestConfig)
+
+type requestConfig struct {
+	anonymous      bool
+	timeout        time.Duration
+	header         http.Header
+	idempotencyKey string
+	noDedup        bool
+	scanned        bool
+}
+
+func newRequestConfig(opts []RequestOption) requestConfig {
+	var cfg requestConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return cfg
+}
+
+// WithoutAuth sends the call anonymously even when the client has a token,
+// to see a paste the way other people do.
+func WithoutAuth() RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.anonymous = true
+	}
+}
+
+// WithCallTimeout replaces the client's timeout for this call. The timeout
+// covers reading the response body too.
+func WithCallTimeout(timeout time.Duration) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithHeader adds a header to the call's requests. Responses to GET requests
+// with extra headers are never served from or stored in the cache.
+func WithHeader(key string, value string) RequestOption {
+	return func(cfg *requestConfig) {
+		if cfg.header == nil {
+			cfg.header = make(http.Header)
+		}
+		cfg.header.Add(key, value)
+	}
+}
+
+// WithIdempotencyKey sends key as the Idempotency-Key header, so a retried
+// request is only applied once. Calls creating several pastes derive a
+// separate key for each of them.
+func WithIdempotencyKey(key string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.idempotencyKey = key
+	}
+}
+
+// withIdempotencySuffix derives the idempotency key of one of the requests of
+// a call from the call's key, if it has one.
+func withIdempotencySuffix(suffix string) RequestOption {
+	return func(cfg *requestConfig) {
+		if cfg.idempotencyKey != "" {
+			cfg.idempotencyKey += "/" + suffix
+		}
+	}
+}
+
+// withScanned marks the call's content as already checked by the client's
+// secret scanner, so it isn't checked and reported again.
+func withScanned() RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.scanned = true
+	}
+}
+
+// appendOptions returns opts followed by extra, without writing to the
+// caller's backing array.
+func appendOptions(opts []RequestOption, extra ...RequestOption) []RequestOption {
+	return append(slices.Clip(opts), extra...)
+}
+
+// requestToken returns the token a call made with opts is sent with, empty
+// when it is sent anonymously.
+func (c *Client) requestToken(ctx context.Context, opts []RequestOption) (string, error) {
+	if newRequestConfig(opts).anonymous {
+		return "", nil
+	}
+
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not get API token: %w", err)
+	}
+
+	return token, nil
+}
+
+// do sends req through the client's circuit breaker, rate limiter and HTTP
+// client, applying the call's timeout.
+func (c *Client) do(req *http.Request, opts []RequestOption) (*http.Response, error) {
+	cfg := newRequestConfig(opts)
+	if cfg.timeout <= 0 {
+		return c.send(c.httpClient, req, req.Context())
+	}
+
+	// A copy sharing the transport, leaving the shared client untouched
+	client := *c.httpClient
+	client.Timeout = 0
+
+	ctx, cancel := context.WithTimeout(req.Context(), cfg.timeout)
+	res, err := c.send(&client, req.WithContext(ctx), req.Context())
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// send checks the circuit breaker, waits on the rate limiter and sends req,
+// reporting the outcome back to the breaker. caller is the context of the
+// call, without the call's timeout.
+func (c *Client) send(client *http.Client, req *http.Request, caller context.Context) (*http.Response, error) {
+	var generation uint64
+	if c.breaker != nil {
+		var err error
+		if generation, err = c.breaker.allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.limiter != nil {
+		if err := c.limiter.Wait(req.Context()); err != nil {
+			if c.breaker != nil {
+				c.breaker.done(generation, circuitIgnored)
+			}
+			return nil, err
+		}
+	}
+
+	var body *trackedBody
+	if c.breaker != nil && req.Body != nil && req.Body != http.NoBody {
+		body = &trackedBody{ReadCloser: req.Body}
+		req.Body = body
+	}
+
+	res, err := client.Do(req)
+	if c.breaker != nil {
+		outcome := circuitResult(res, err)
+		// Failures of the caller's own making say nothing about the API
+		if err != nil && (caller.Err() != nil || (body != nil && body.failed.Load())) {
+			outcome = circuitIgnored
+		}
+		c.breaker.done(generation, outcome)
+	}
+
+	return res, err
+}
+
+// trackedBody remembers whether reading a request body failed, like a
+// streamed body going over the size limit. The transport may still be
+// reading it when Do returns.
+type trackedBody struct {
+	io.ReadCloser
+	failed atomic.Bool
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.failed.Store(true)
+	}
+	return n, err
+}
+
+// cancelOnClose releases the call's timeout once its body has been read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,406 @@
+package gopastemyst
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultEntropyThreshold is the Shannon entropy, in bits per character, above
+// which a long token is reported as a possible secret.
+const DefaultEntropyThreshold = 4.5
+
+// DefaultEntropyMinLength is the shortest token checked for high entropy.
+const DefaultEntropyMinLength = 32
+
+// ScanMode is what the client does when the secret scanner finds something.
+type ScanMode int
+
+const (
+	// Refuse to send the content, returning a *SecretsFoundError
+	ScanBlock ScanMode = iota
+	// Send the content as is, only reporting the findings
+	ScanWarn
+	// Replace the secrets with a placeholder before sending
+	ScanRedact
+)
+
+func (m ScanMode) String() string {
+	switch m {
+	case ScanBlock:
+		return "block"
+	case ScanWarn:
+		return "warn"
+	case ScanRedact:
+		return "redact"
+	default:
+		return fmt.Sprintf("ScanMode(%d)", int(m))
+	}
+}
+
+// SecretRule detects one kind of secret. When Group is set, only that
+// submatch of Pattern is the secret, and the rest of the match is context.
+type SecretRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Group   int
+}
+
+// DefaultSecretRules are the rules a SecretScanner uses unless told otherwise.
+var DefaultSecretRules = []SecretRule{
+	{
+		Name:    "aws-access-key-id",
+		Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`),
+	},
+	{
+		Name:    "aws-secret-access-key",
+		Pattern: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})\b`),
+		Group:   1,
+	},
+	{
+		Name:    "private-key",
+		Pattern: regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----(?:[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----)?`),
+	},
+	{
+		Name:    "jwt",
+		Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`),
+	},
+	{
+		Name:    "bearer-token",
+		Pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{16,}=*)`),
+		Group:   1,
+	},
+}
+
+// Candidates for the high entropy check
+var entropyTokenPattern = regexp.MustCompile(`[A-Za-z0-9+/_-]+=*`)
+
+type SecretScannerOptions struct {
+	Mode ScanMode
+
+	// Rules checked on top of DefaultSecretRules
+	Rules []SecretRule
+	// Only check Rules, leaving out DefaultSecretRules
+	DisableDefaultRules bool
+
+	// Entropy above which mixed case alphanumeric tokens are reported. Zero
+	// means DefaultEntropyThreshold and a negative value disables the check.
+	EntropyThreshold float64
+	// Zero means DefaultEntropyMinLength
+	EntropyMinLength int
+
+	// Called with the findings of every scan that found something, whatever
+	// the mode
+	OnReport func(report *SecretReport)
+}
+
+// SecretScanner looks for credentials in pasty content. Set one on a client
+// with WithSecretScanner to check content before it is sent.
+type SecretScanner struct {
+	mode             ScanMode
+	rules            []SecretRule
+	entropyThreshold float64
+	entropyMinLength int
+	onReport         func(*SecretReport)
+}
+
+func NewSecretScanner(options SecretScannerOptions) *SecretScanner {
+	s := &SecretScanner{
+		mode:             options.Mode,
+		entropyThreshold: options.EntropyThreshold,
+		entropyMinLength: options.EntropyMinLength,
+		onReport:         options.OnReport,
+	}
+
+	if !options.DisableDefaultRules {
+		s.rules = append(s.rules, DefaultSecretRules...)
+	}
+	s.rules = append(s.rules, options.Rules...)
+
+	if s.entropyThreshold == 0 {
+		s.entropyThreshold = DefaultEntropyThreshold
+	}
+	if s.entropyMinLength <= 0 {
+		s.entropyMinLength = DefaultEntropyMinLength
+	}
+
+	return s
+}
+
+// WithSecretScanner makes CreatePaste and EditPaste, and everything built on
+// them, scan pasty content with scanner before sending it, then block, warn
+// or redact according to its mode. CreatePasteFromReader reads the content
+// into memory to scan it, instead of streaming it.
+func WithSecretScanner(scanner *SecretScanner) ClientOption {
+	return func(c *Client) {
+		c.scanner = scanner
+	}
+}
+
+// SecretFinding is a possible secret found in pasty content. Offsets, lines
+// and columns are in bytes and refer to the content before any redaction.
+type SecretFinding struct {
+	Rule       string
+	Pasty      int
+	PastyTitle string
+	Offset     int
+	Length     int
+	Line       int
+	Column     int
+
+	// Start of the secret with the rest masked, safe to log
+	Preview string
+}
+
+type SecretReport struct {
+	Mode     ScanMode
+	Findings []SecretFinding
+}
+
+// ErrSecretsFound is matched by errors.Is when the scanner blocked content.
+// Use errors.As with a *SecretsFoundError to get the report.
+var ErrSecretsFound = errors.New("possible secrets found in paste content")
+
+type SecretsFoundError struct {
+	Report *SecretReport
+}
+
+func (e *SecretsFoundError) Error() string {
+	first := e.Report.Findings[0]
+	return fmt.Sprintf("%s: %d finding(s), first is %s in pasty %q at line %d",
+		ErrSecretsFound, len(e.Report.Findings), first.Rule, first.PastyTitle, first.Line)
+}
+
+func (e *SecretsFoundError) Is(target error) bool {
+	return target == ErrSecretsFound
+}
+
+// Scan returns the possible secrets in content, ordered by offset. Pasty and
+// PastyTitle are left empty.
+func (s *SecretScanner) Scan(content string) []SecretFinding {
+	var findings []SecretFinding
+
+	for _, rule := range s.rules {
+		for _, loc := range rule.Pattern.FindAllStringSubmatchIndex(content, -1) {
+			start, end := loc[0], loc[1]
+			if rule.Group > 0 {
+				if 2*rule.Group+1 >= len(loc) || loc[2*rule.Group] < 0 {
+					continue
+				}
+				start, end = loc[2*rule.Group], loc[2*rule.Group+1]
+			}
+
+			findings = append(findings, newSecretFinding(rule.Name, content, start, end))
+		}
+	}
+
+	if s.entropyThreshold > 0 {
+		for _, loc := range entropyTokenPattern.FindAllStringIndex(content, -1) {
+			token := content[loc[0]:loc[1]]
+			if len(token) < s.entropyMinLength || !mixedAlphanumeric(token) {
+				continue
+			}
+			if shannonEntropy(token) < s.entropyThreshold {
+				continue
+			}
+
+			findings = append(findings, newSecretFinding("high-entropy", content, loc[0], loc[1]))
+		}
+	}
+
+	// Keep the longest of overlapping findings, so a JWT sent as a bearer
+	// token or a key caught by the entropy check are reported once
+	slices.SortStableFunc(findings, func(a, b SecretFinding) int {
+		if a.Offset != b.Offset {
+			return a.Offset - b.Offset
+		}
+		return b.Length - a.Length
+	})
+
+	kept := findings[:0]
+	for _, finding := range findings {
+		if len(kept) > 0 {
+			last := kept[len(kept)-1]
+			if finding.Offset < last.Offset+last.Length {
+				continue
+			}
+		}
+		kept = append(kept, finding)
+	}
+
+	return kept
+}
+
+// ScanPaste scans every pasty of a paste about to be created.
+func (s *SecretScanner) ScanPaste(options CreatePasteOptions) *SecretReport {
+	report := &SecretReport{Mode: s.mode}
+	for i, pasty := range options.Pasties {
+		report.add(s.Scan(pasty.Content), i, pasty.Title)
+	}
+
+	return report
+}
+
+// ScanEdit scans every pasty of an edit about to be sent.
+func (s *SecretScanner) ScanEdit(options EditPasteOptions) *SecretReport {
+	report := &SecretReport{Mode: s.mode}
+	for i, pasty := range options.Pasties {
+		report.add(s.Scan(pasty.Content), i, pasty.Title)
+	}
+
+	return report
+}
+
+// Redact returns content with every finding replaced by a placeholder naming
+// its rule.
+func Redact(content string, findings []SecretFinding) string {
+	var b strings.Builder
+	b.Grow(len(content))
+
+	last := 0
+	for _, finding := range findings {
+		if finding.Offset < last {
+			continue
+		}
+		b.WriteString(content[last:finding.Offset])
+		fmt.Fprintf(&b, "[REDACTED %s]", finding.Rule)
+		last = finding.Offset + finding.Length
+	}
+	b.WriteString(content[last:])
+
+	return b.String()
+}
+
+func (r *SecretReport) add(findings []SecretFinding, pasty int, title string) {
+	for _, finding := range findings {
+		finding.Pasty = pasty
+		finding.PastyTitle = title
+		r.Findings = append(r.Findings, finding)
+	}
+}
+
+// findingsFor returns the findings of the pasty at index pasty.
+func (r *SecretReport) findingsFor(pasty int) []SecretFinding {
+	var findings []SecretFinding
+	for _, finding := range r.Findings {
+		if finding.Pasty == pasty {
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings
+}
+
+// checkPaste applies the scanner to a paste about to be created, returning
+// the options to send.
+func (s *SecretScanner) checkPaste(options CreatePasteOptions) (CreatePasteOptions, error) {
+	report := s.ScanPaste(options)
+	if err := s.handle(report); err != nil || s.mode != ScanRedact || len(report.Findings) == 0 {
+		return options, err
+	}
+
+	// The caller's pasties share the backing array, so never redact in place
+	options.Pasties = slices.Clone(options.Pasties)
+	for i := range options.Pasties {
+		options.Pasties[i].Content = Redact(options.Pasties[i].Content, report.findingsFor(i))
+	}
+
+	return options, nil
+}
+
+// checkEdit is checkPaste for edits.
+func (s *SecretScanner) checkEdit(options EditPasteOptions) (EditPasteOptions, error) {
+	report := s.ScanEdit(options)
+	if err := s.handle(report); err != nil || s.mode != ScanRedact || len(report.Findings) == 0 {
+		return options, err
+	}
+
+	options.Pasties = slices.Clone(options.Pasties)
+	for i := range options.Pasties {
+		options.Pasties[i].Content = Redact(options.Pasties[i].Content, report.findingsFor(i))
+	}
+
+	return options, nil
+}
+
+func (s *SecretScanner) handle(report *SecretReport) error {
+	if len(report.Findings) == 0 {
+		return nil
+	}
+
+	if s.onReport != nil {
+		s.onReport(report)
+	}
+
+	if s.mode == ScanBlock {
+		return &SecretsFoundError{Report: report}
+	}
+
+	return nil
+}
+
+func newSecretFinding(rule string, content string, start int, end int) SecretFinding {
+	line := 1 + strings.Count(content[:start], "\n")
+	column := start - strings.LastIndexByte(content[:start], '\n')
+
+	return SecretFinding{
+		Rule:    rule,
+		Offset:  start,
+		Length:  end - start,
+		Line:    line,
+		Column:  column,
+		Preview: maskSecret(content[start:end]),
+	}
+}
+
+// maskSecret keeps only the first few characters of a secret, and only when
+// it is long enough for that not to give much of it away.
+func maskSecret(secret string) string {
+	secret, _, _ = strings.Cut(secret, "\n")
+
+	keep := 0
+	if len(secret) >= 16 {
+		keep = 4
+	}
+
+	return secret[:keep] + strings.Repeat("*", min(len(secret)-keep, 8))
+}
+
+// mixedAlphanumeric reports whether token has lower case and upper case
+// letters and digits, which rules out most words, paths and hex hashes.
+func mixedAlphanumeric(token string) bool {
+	var lower, upper, digit bool
+	for _, r := range token {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+
+	return lower && upper && digit
+}
+
+func shannonEntropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
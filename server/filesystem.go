@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Sammie156/go-pastemyst/internal/fileutil"
+)
+
+// FileStore is a Store keeping every paste and user as a JSON file:
+//
+//	<dir>/pastes/<id>.json
+//	<dir>/users/<username>.json
+type FileStore struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	for _, sub := range []string{"pastes", "users"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("could not create store directory: %w", err)
+		}
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+func (f *FileStore) pastePath(id string) string {
+	return filepath.Join(f.dir, "pastes", filepath.Base(id)+".json")
+}
+
+func (f *FileStore) userPath(username string) string {
+	return filepath.Join(f.dir, "users", filepath.Base(username)+".json")
+}
+
+func (f *FileStore) GetPaste(_ context.Context, id string) (*Record, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var record Record
+	if err := readFile(f.pastePath(id), &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (f *FileStore) PutPaste(_ context.Context, record *Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return writeFile(f.pastePath(record.Paste.ID), record)
+}
+
+func (f *FileStore) DeletePaste(_ context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := os.Remove(f.pastePath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func (f *FileStore) ListPastes(_ context.Context, ownerID string) ([]*Record, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	entries, err := os.ReadDir(filepath.Join(f.dir, "pastes"))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		var record Record
+		if err := readFile(filepath.Join(f.dir, "pastes", entry.Name()), &record); err != nil {
+			return nil, err
+		}
+
+		if ownerID == "" || isOwner(&record, ownerID) {
+			records = append(records, &record)
+		}
+	}
+
+	return records, nil
+}
+
+func (f *FileStore) GetUser(_ context.Context, username string) (*UserRecord, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var user UserRecord
+	if err := readFile(f.userPath(username), &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (f *FileStore) GetUserByToken(_ context.Context, tokenHash string) (*UserRecord, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	entries, err := os.ReadDir(filepath.Join(f.dir, "users"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		var user UserRecord
+		if err := readFile(filepath.Join(f.dir, "users", entry.Name()), &user); err != nil {
+			return nil, err
+		}
+
+		if user.TokenHash == tokenHash {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (f *FileStore) PutUser(_ context.Context, user *UserRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return writeFile(f.userPath(user.User.Username), user)
+}
+
+func readFile(path string, value any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("could not decode %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// writeFile replaces the file at path through a temporary file, so readers
+// never see it half written.
+func writeFile(path string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return fileutil.WriteFileAtomic(filepath.Dir(path), path, data)
+}
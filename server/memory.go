@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// MemoryStore is a Store keeping everything in memory, lost when the process
+// exits.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	// Records are kept encoded so callers never share them with the store
+	pastes map[string][]byte
+	users  map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		pastes: make(map[string][]byte),
+		users:  make(map[string][]byte),
+	}
+}
+
+func (m *MemoryStore) GetPaste(_ context.Context, id string) (*Record, error) {
+	m.mu.RLock()
+	data, ok := m.pastes[id]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (m *MemoryStore) PutPaste(_ context.Context, record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.pastes[record.Paste.ID] = data
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *MemoryStore) DeletePaste(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.pastes[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.pastes, id)
+
+	return nil
+}
+
+func (m *MemoryStore) ListPastes(_ context.Context, ownerID string) ([]*Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var records []*Record
+	for _, data := range m.pastes {
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, err
+		}
+
+		if ownerID == "" || isOwner(&record, ownerID) {
+			records = append(records, &record)
+		}
+	}
+
+	return records, nil
+}
+
+func (m *MemoryStore) GetUser(_ context.Context, username string) (*UserRecord, error) {
+	m.mu.RLock()
+	data, ok := m.users[username]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var user UserRecord
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (m *MemoryStore) GetUserByToken(_ context.Context, tokenHash string) (*UserRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, data := range m.users {
+		var user UserRecord
+		if err := json.Unmarshal(data, &user); err != nil {
+			return nil, err
+		}
+
+		if user.TokenHash == tokenHash {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) PutUser(_ context.Context, user *UserRecord) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.users[user.User.Username] = data
+	m.mu.Unlock()
+
+	return nil
+}
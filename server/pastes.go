@@ -0,0 +1,556 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	gopastemyst "github.com/Sammie156/go-pastemyst"
+)
+
+// Lifetimes accepted in ExpiresIn, zero meaning the paste never expires
+var expiryDurations = map[string]time.Duration{
+	"never": 0,
+	"1h":    time.Hour,
+	"2h":    2 * time.Hour,
+	"10h":   10 * time.Hour,
+	"1d":    24 * time.Hour,
+	"2d":    48 * time.Hour,
+	"1w":    7 * 24 * time.Hour,
+	"1m":    30 * 24 * time.Hour,
+	"1y":    365 * 24 * time.Hour,
+}
+
+const defaultPastyLanguage = "Text"
+
+func (s *Server) createPaste(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.requestUser(w, r, false)
+	if !ok {
+		return
+	}
+
+	var options gopastemyst.CreatePasteOptions
+	if !s.decodeBody(w, r, &options) {
+		return
+	}
+
+	if len(options.Pasties) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one pasty should be present")
+		return
+	}
+
+	var size int64
+	for _, pasty := range options.Pasties {
+		size += int64(len(pasty.Content))
+	}
+	if size > s.maxPasteSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "paste content exceeds the size limit")
+		return
+	}
+
+	if options.ExpiresIn == "" {
+		options.ExpiresIn = "never"
+	}
+	lifetime, ok := expiryDurations[options.ExpiresIn]
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid expiresIn %q", options.ExpiresIn))
+		return
+	}
+
+	owned := user != nil && !options.Anonymous
+	if !owned && (options.Private || options.Pinned || len(options.Tags) > 0) {
+		writeError(w, http.StatusUnauthorized, "only pastes with an owner can be private, pinned or tagged")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.newPasteID(r.Context())
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	paste := gopastemyst.Paste{
+		ID:        id,
+		Title:     options.Title,
+		CreatedAt: now,
+		ExpiresIn: options.ExpiresIn,
+		Pinned:    options.Pinned,
+		Private:   options.Private,
+		Tags:      normalizeTags(options.Tags),
+		Pasties:   make([]gopastemyst.Pasty, 0, len(options.Pasties)),
+	}
+
+	if owned {
+		paste.OwnerID = &user.User.ID
+	}
+	if lifetime > 0 {
+		deletesAt := now.Add(lifetime)
+		paste.DeletesAt = &deletesAt
+	}
+
+	for _, pasty := range options.Pasties {
+		paste.Pasties = append(paste.Pasties, gopastemyst.Pasty{
+			ID:       newUniqueID(func(id string) bool { return hasPasty(paste.Pasties, id) }),
+			Title:    pasty.Title,
+			Content:  pasty.Content,
+			Language: languageOrDefault(pasty.Language),
+		})
+	}
+
+	record := &Record{
+		Paste:     paste,
+		Encrypted: options.Encrypted,
+		StarredBy: []string{},
+		Original:  paste,
+	}
+
+	if err := s.store.PutPaste(r.Context(), record); err != nil {
+		s.internalError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, record.Paste)
+}
+
+func (s *Server) getPaste(w http.ResponseWriter, r *http.Request) {
+	if id, ok := strings.CutSuffix(r.PathValue("id"), ".zip"); ok {
+		s.getPasteZip(w, r, id)
+		return
+	}
+
+	record, ok := s.loadPaste(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, record.Paste)
+}
+
+func (s *Server) getPasteZip(w http.ResponseWriter, r *http.Request, id string) {
+	record, ok := s.loadPaste(w, r, id)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	used := make(map[string]bool)
+
+	for _, pasty := range record.Paste.Pasties {
+		name := zipFileName(pasty)
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s (%d)", zipFileName(pasty), i)
+		}
+		used[name] = true
+
+		file, err := archive.Create(name)
+		if err != nil {
+			s.internalError(w, err)
+			return
+		}
+		if _, err := file.Write([]byte(pasty.Content)); err != nil {
+			s.internalError(w, err)
+			return
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		s.internalError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", record.Paste.ID+".zip"))
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) editPaste(w http.ResponseWriter, r *http.Request) {
+	var options gopastemyst.EditPasteOptions
+	if !s.decodeBody(w, r, &options) {
+		return
+	}
+
+	s.updatePaste(w, r, func(record *Record) (int, error) {
+		paste := &record.Paste
+
+		if options.Title != "" {
+			paste.Title = options.Title
+		}
+
+		if options.Pasties != nil {
+			pasties, err := editPasties(paste.Pasties, options.Pasties)
+			if err != nil {
+				return http.StatusBadRequest, err
+			}
+
+			var size int64
+			for _, pasty := range pasties {
+				size += int64(len(pasty.Content))
+			}
+			if size > s.maxPasteSize {
+				return http.StatusRequestEntityTooLarge, errors.New("paste content exceeds the size limit")
+			}
+
+			paste.Pasties = pasties
+		}
+
+		if options.Tags != nil {
+			paste.Tags = normalizeTags(*options.Tags)
+		}
+
+		editedAt := time.Now().UTC()
+		paste.EditedAt = &editedAt
+		revisionID := newUniqueID(func(id string) bool {
+			return slices.ContainsFunc(record.History, func(revision Revision) bool { return revision.ID == id })
+		})
+		record.History = append(record.History, Revision{
+			ID:       revisionID,
+			EditedAt: editedAt,
+			Paste:    *paste,
+		})
+
+		return http.StatusOK, nil
+	})
+}
+
+// editPasties applies an edit to the pasties of a paste. Pasties are matched
+// by ID, those without one are added and those left out are removed. Empty
+// fields keep their current value, as the client omits them.
+func editPasties(current []gopastemyst.Pasty, edits []gopastemyst.EditPastyOptions) ([]gopastemyst.Pasty, error) {
+	if len(edits) == 0 {
+		return nil, errors.New("at least one pasty should be present")
+	}
+
+	pasties := make([]gopastemyst.Pasty, 0, len(edits))
+	for _, edit := range edits {
+		if edit.ID == "" {
+			// IDs of pasties removed by this edit aren't reused either
+			id := newUniqueID(func(id string) bool { return hasPasty(current, id) || hasPasty(pasties, id) })
+			pasties = append(pasties, gopastemyst.Pasty{
+				ID:       id,
+				Title:    edit.Title,
+				Content:  edit.Content,
+				Language: languageOrDefault(edit.Language),
+			})
+			continue
+		}
+
+		index := slices.IndexFunc(current, func(p gopastemyst.Pasty) bool { return p.ID == edit.ID })
+		if index < 0 {
+			return nil, fmt.Errorf("paste has no pasty %s", edit.ID)
+		}
+
+		pasty := current[index]
+		if edit.Title != "" {
+			pasty.Title = edit.Title
+		}
+		if edit.Content != "" {
+			pasty.Content = edit.Content
+		}
+		if edit.Language != "" {
+			pasty.Language = edit.Language
+		}
+		pasties = append(pasties, pasty)
+	}
+
+	return pasties, nil
+}
+
+func (s *Server) deletePaste(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.requestUser(w, r, true)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.loadPaste(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	if !isOwner(record, user.User.ID) {
+		writeError(w, http.StatusForbidden, "only the owner can delete this paste")
+		return
+	}
+
+	if err := s.store.DeletePaste(r.Context(), record.Paste.ID); err != nil && !errors.Is(err, ErrNotFound) {
+		s.internalError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getPasteStats(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.loadPaste(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, gopastemyst.ComputeStats(record.Paste))
+}
+
+func (s *Server) getPasteLangs(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.loadPaste(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, languageStats(record.Paste))
+}
+
+func (s *Server) getHistory(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.loadPaste(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	// Newest edit first
+	history := make([]gopastemyst.CompactPasteHistory, 0, len(record.History))
+	for _, revision := range slices.Backward(record.History) {
+		history = append(history, gopastemyst.CompactPasteHistory{
+			ID:       revision.ID,
+			EditedAt: revision.EditedAt,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+func (s *Server) getPasteAtEdit(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.loadPaste(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	index, ok := findRevision(w, record, r.PathValue("historyID"))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, record.History[index].Paste)
+}
+
+func (s *Server) getDiff(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.loadPaste(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	index, ok := findRevision(w, record, r.PathValue("historyID"))
+	if !ok {
+		return
+	}
+
+	old := record.Original
+	if index > 0 {
+		old = record.History[index-1].Paste
+	}
+
+	writeJSON(w, http.StatusOK, gopastemyst.PasteDiff{
+		CurrentPaste: record.Paste,
+		NewPaste:     record.History[index].Paste,
+		OldPaste:     old,
+	})
+}
+
+func findRevision(w http.ResponseWriter, record *Record, historyID string) (int, bool) {
+	index := slices.IndexFunc(record.History, func(rev Revision) bool { return rev.ID == historyID })
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "history entry not found")
+		return 0, false
+	}
+
+	return index, true
+}
+
+func (s *Server) getEncrypted(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.loadPaste(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	writeBool(w, record.Encrypted)
+}
+
+func (s *Server) isStarred(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.requestUser(w, r, true)
+	if !ok {
+		return
+	}
+
+	record, ok := s.loadPaste(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	writeBool(w, slices.Contains(record.StarredBy, user.User.ID))
+}
+
+func (s *Server) toggleStar(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.requestUser(w, r, true)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.loadPaste(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	if i := slices.Index(record.StarredBy, user.User.ID); i >= 0 {
+		record.StarredBy = slices.Delete(record.StarredBy, i, i+1)
+	} else {
+		record.StarredBy = append(record.StarredBy, user.User.ID)
+	}
+	record.Paste.Stars = len(record.StarredBy)
+
+	if err := s.store.PutPaste(r.Context(), record); err != nil {
+		s.internalError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, record.Paste)
+}
+
+func (s *Server) togglePin(w http.ResponseWriter, r *http.Request) {
+	s.updatePaste(w, r, func(record *Record) (int, error) {
+		record.Paste.Pinned = !record.Paste.Pinned
+		return http.StatusOK, nil
+	})
+}
+
+func (s *Server) togglePrivate(w http.ResponseWriter, r *http.Request) {
+	s.updatePaste(w, r, func(record *Record) (int, error) {
+		record.Paste.Private = !record.Paste.Private
+		return http.StatusOK, nil
+	})
+}
+
+// updatePaste loads the paste of the request, checks that the requester
+// owns it and stores it again after change. Errors returned by change are
+// sent with the status it returns.
+func (s *Server) updatePaste(w http.ResponseWriter, r *http.Request, change func(record *Record) (int, error)) {
+	user, ok := s.requestUser(w, r, true)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.loadPaste(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	if !isOwner(record, user.User.ID) {
+		writeError(w, http.StatusForbidden, "only the owner can change this paste")
+		return
+	}
+
+	if status, err := change(record); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	if err := s.store.PutPaste(r.Context(), record); err != nil {
+		s.internalError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, record.Paste)
+}
+
+// loadPaste fetches the paste with the given ID for the request, answering
+// 404 when it doesn't exist, has expired or is private to someone else, like
+// the public instance does.
+func (s *Server) loadPaste(w http.ResponseWriter, r *http.Request, id string) (*Record, bool) {
+	user, ok := s.requestUser(w, r, false)
+	if !ok {
+		return nil, false
+	}
+
+	record, err := s.store.GetPaste(r.Context(), id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		s.internalError(w, err)
+		return nil, false
+	}
+
+	visible := err == nil && !expired(record, time.Now()) &&
+		(!record.Paste.Private || (user != nil && isOwner(record, user.User.ID)))
+	if !visible {
+		writeError(w, http.StatusNotFound, "paste not found")
+		return nil, false
+	}
+
+	return record, true
+}
+
+func hasPasty(pasties []gopastemyst.Pasty, id string) bool {
+	return slices.ContainsFunc(pasties, func(p gopastemyst.Pasty) bool { return p.ID == id })
+}
+
+func isOwner(record *Record, userID string) bool {
+	return record.Paste.OwnerID != nil && *record.Paste.OwnerID == userID
+}
+
+func expired(record *Record, now time.Time) bool {
+	return record.Paste.DeletesAt != nil && !now.Before(*record.Paste.DeletesAt)
+}
+
+func writeBool(w http.ResponseWriter, value bool) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(strconv.FormatBool(value)))
+}
+
+// normalizeTags trims and dedupes tags, never returning nil so pastes always
+// encode an array.
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+
+	return normalized
+}
+
+func languageOrDefault(language string) string {
+	if language == "" {
+		return defaultPastyLanguage
+	}
+
+	return language
+}
+
+func zipFileName(pasty gopastemyst.Pasty) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, pasty.Title)
+
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		return pasty.ID
+	}
+
+	return name
+}
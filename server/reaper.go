@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Reap deletes every paste whose expiry has passed and returns how many were
+// deleted. Expired pastes are already hidden from requests, this frees their
+// storage.
+func (s *Server) Reap(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.store.ListPastes(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	now := time.Now()
+	for _, record := range records {
+		if !expired(record, now) {
+			continue
+		}
+
+		if err := s.store.DeletePaste(ctx, record.Paste.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// RunReaper calls Reap every ReapInterval until ctx is done. Errors are
+// logged and the next run tries again.
+func (s *Server) RunReaper(ctx context.Context) {
+	ticker := time.NewTicker(s.reapInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Reap(ctx); err != nil && ctx.Err() == nil {
+			s.logf("pastemyst-server: could not delete expired pastes: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
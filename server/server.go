@@ -0,0 +1,263 @@
+// Package server is a self-hosted implementation of the PasteMyst v3 API
+// endpoints used by gopastemyst.Client, for networks that can't reach the
+// public instance. Point a client at it with gopastemyst.WithBaseURL.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	gopastemyst "github.com/Sammie156/go-pastemyst"
+)
+
+// APIPrefix is the path the API is served under, so clients use
+// http://host/api/v3 as their base URL like with the public instance.
+const APIPrefix = "/api/v3"
+
+// DefaultPageSize is used when a user paste listing asks for no page size.
+const DefaultPageSize = 10
+
+// DefaultReapInterval is how often expired pastes are deleted when Options
+// gives no interval.
+const DefaultReapInterval = time.Minute
+
+type Options struct {
+	// How often RunReaper deletes expired pastes
+	ReapInterval time.Duration
+
+	// Receives errors that can't be reported to a client, log.Printf if nil
+	Logf func(format string, args ...any)
+
+	// Largest content of a paste across all pasties, in bytes. Zero means
+	// gopastemyst.DefaultMaxPasteSize, the limit the client applies.
+	MaxPasteSize int64
+}
+
+type Server struct {
+	store        Store
+	reapInterval time.Duration
+	logf         func(format string, args ...any)
+	maxPasteSize int64
+	mux          *http.ServeMux
+
+	// Serializes read-modify-write cycles on the store
+	mu sync.Mutex
+}
+
+func New(store Store, options Options) *Server {
+	s := &Server{
+		store:        store,
+		reapInterval: options.ReapInterval,
+		logf:         options.Logf,
+		maxPasteSize: options.MaxPasteSize,
+		mux:          http.NewServeMux(),
+	}
+
+	if s.reapInterval <= 0 {
+		s.reapInterval = DefaultReapInterval
+	}
+	if s.logf == nil {
+		s.logf = log.Printf
+	}
+	if s.maxPasteSize <= 0 {
+		s.maxPasteSize = gopastemyst.DefaultMaxPasteSize
+	}
+
+	s.routes()
+
+	return s
+}
+
+func (s *Server) routes() {
+	handle := func(pattern string, handler http.HandlerFunc) {
+		method, path, _ := strings.Cut(pattern, " ")
+		s.mux.HandleFunc(method+" "+APIPrefix+path, handler)
+	}
+
+	handle("POST /pastes", s.createPaste)
+	// Also serves /pastes/{id}.zip, which a wildcard can't express
+	handle("GET /pastes/{id}", s.getPaste)
+	handle("PATCH /pastes/{id}", s.editPaste)
+	handle("DELETE /pastes/{id}", s.deletePaste)
+	handle("GET /pastes/{id}/stats", s.getPasteStats)
+	handle("GET /pastes/{id}/langs", s.getPasteLangs)
+	handle("GET /pastes/{id}/history_compact", s.getHistory)
+	handle("GET /pastes/{id}/history/{historyID}", s.getPasteAtEdit)
+	handle("GET /pastes/{id}/history/{historyID}/diff", s.getDiff)
+	handle("GET /pastes/{id}/encrypted", s.getEncrypted)
+	handle("GET /pastes/{id}/star", s.isStarred)
+	handle("POST /pastes/{id}/star", s.toggleStar)
+	handle("POST /pastes/{id}/pin", s.togglePin)
+	handle("POST /pastes/{id}/private", s.togglePrivate)
+
+	handle("GET /users/{username}", s.getUser)
+	handle("GET /users/{username}/pastes", s.getUserPastes)
+	handle("GET /users/{username}/tags", s.getUserTags)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// AddUser creates a user whose requests authenticate with token, or gives an
+// existing user that token instead of their previous one.
+func (s *Server) AddUser(ctx context.Context, username string, token string) (*gopastemyst.User, error) {
+	if username == "" || strings.ContainsAny(username, `/\`) {
+		return nil, fmt.Errorf("invalid username %q", username)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("token for %s is empty", username)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if other, err := s.store.GetUserByToken(ctx, HashToken(token)); err == nil && other.User.Username != username {
+		return nil, fmt.Errorf("token is already used by %s", other.User.Username)
+	}
+
+	user, err := s.store.GetUser(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		user = &UserRecord{User: gopastemyst.User{
+			ID:        newID(),
+			Username:  username,
+			CreatedAt: time.Now().UTC(),
+		}}
+	} else if err != nil {
+		return nil, err
+	}
+
+	user.TokenHash = HashToken(token)
+	if err := s.store.PutUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &user.User, nil
+}
+
+// errUnauthorized is returned by authenticate for a token no user has.
+var errUnauthorized = errors.New("invalid API token")
+
+// authenticate returns the user the request's bearer token belongs to, or nil
+// for anonymous requests.
+func (s *Server) authenticate(r *http.Request) (*UserRecord, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, nil
+	}
+
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return nil, errUnauthorized
+	}
+
+	user, err := s.store.GetUserByToken(r.Context(), HashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return nil, errUnauthorized
+	}
+
+	return user, err
+}
+
+// requestUser is authenticate for handlers, writing the error response
+// itself. ok is false when the handler should stop.
+func (s *Server) requestUser(w http.ResponseWriter, r *http.Request, required bool) (user *UserRecord, ok bool) {
+	user, err := s.authenticate(r)
+	switch {
+	case errors.Is(err, errUnauthorized):
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return nil, false
+	case err != nil:
+		s.internalError(w, err)
+		return nil, false
+	case user == nil && required:
+		writeError(w, http.StatusUnauthorized, "this action requires an API token")
+		return nil, false
+	}
+
+	return user, true
+}
+
+// decodeBody decodes the JSON body of the request into value, writing the
+// error response itself. ok is false when the handler should stop.
+func (s *Server) decodeBody(w http.ResponseWriter, r *http.Request, value any) (ok bool) {
+	// Escaping can make content up to six times longer, as \u00XX, and the
+	// other fields need some room too
+	r.Body = http.MaxBytesReader(w, r.Body, 6*s.maxPasteSize+1<<20)
+
+	if err := json.NewDecoder(r.Body).Decode(value); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body is too large")
+			return false
+		}
+
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) internalError(w http.ResponseWriter, err error) {
+	s.logf("pastemyst-server: %v", err)
+	writeError(w, http.StatusInternalServerError, "internal server error")
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, gopastemyst.APIError{StatusMessage: message})
+}
+
+const idAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// newPasteID returns a newID no paste has. Expired pastes keep their ID until
+// the reaper deletes them. The caller must hold s.mu until the paste is
+// stored.
+func (s *Server) newPasteID(ctx context.Context) (string, error) {
+	for {
+		id := newID()
+
+		_, err := s.store.GetPaste(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			return id, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// newUniqueID returns a newID for which taken is false.
+func newUniqueID(taken func(id string) bool) string {
+	for {
+		if id := newID(); !taken(id) {
+			return id
+		}
+	}
+}
+
+// newID returns a random 8 character ID like the ones PasteMyst hands out.
+func newID() string {
+	var b [8]byte
+	rand.Read(b[:])
+
+	for i := range b {
+		b[i] = idAlphabet[int(b[i])%len(idAlphabet)]
+	}
+
+	return string(b[:])
+}
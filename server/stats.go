@@ -0,0 +1,41 @@
+package server
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	gopastemyst "github.com/Sammie156/go-pastemyst"
+)
+
+// languageStats splits a paste by language, weighted by content size.
+func languageStats(paste gopastemyst.Paste) []gopastemyst.PasteLanguageStats {
+	total := 0
+	sizes := make(map[string]int)
+	for _, pasty := range paste.Pasties {
+		sizes[pasty.Language] += len(pasty.Content)
+		total += len(pasty.Content)
+	}
+
+	stats := make([]gopastemyst.PasteLanguageStats, 0, len(sizes))
+	for language, size := range sizes {
+		percentage := 0.0
+		if total > 0 {
+			percentage = 100 * float64(size) / float64(total)
+		}
+
+		stats = append(stats, gopastemyst.PasteLanguageStats{
+			Language:   gopastemyst.LanguageStats{Name: language},
+			Percentage: percentage,
+		})
+	}
+
+	slices.SortFunc(stats, func(a, b gopastemyst.PasteLanguageStats) int {
+		if c := cmp.Compare(b.Percentage, a.Percentage); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Language.Name, b.Language.Name)
+	})
+
+	return stats
+}
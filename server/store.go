@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	gopastemyst "github.com/Sammie156/go-pastemyst"
+)
+
+// ErrNotFound is returned by a Store when the paste or user doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// Record is everything the server keeps about a paste.
+type Record struct {
+	Paste     gopastemyst.Paste `json:"paste"`
+	Encrypted bool              `json:"encrypted"`
+
+	// IDs of the users who starred the paste
+	StarredBy []string `json:"starredBy"`
+
+	// The paste as it was created, and after every edit since, oldest first
+	Original gopastemyst.Paste `json:"original"`
+	History  []Revision        `json:"history"`
+}
+
+type Revision struct {
+	ID       string            `json:"id"`
+	EditedAt time.Time         `json:"editedAt"`
+	Paste    gopastemyst.Paste `json:"paste"`
+}
+
+// UserRecord is a user along with the hash of their API token.
+type UserRecord struct {
+	User      gopastemyst.User `json:"user"`
+	TokenHash string           `json:"tokenHash"`
+}
+
+// Store persists pastes and users. Implementations must be safe for
+// concurrent use, but the server serializes its own read-modify-write cycles.
+type Store interface {
+	GetPaste(ctx context.Context, id string) (*Record, error)
+	PutPaste(ctx context.Context, record *Record) error
+	DeletePaste(ctx context.Context, id string) error
+	// ListPastes returns the pastes owned by ownerID, or every paste when
+	// ownerID is empty
+	ListPastes(ctx context.Context, ownerID string) ([]*Record, error)
+
+	GetUser(ctx context.Context, username string) (*UserRecord, error)
+	GetUserByToken(ctx context.Context, tokenHash string) (*UserRecord, error)
+	PutUser(ctx context.Context, user *UserRecord) error
+}
+
+// HashToken returns the hash tokens are stored and looked up by.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	gopastemyst "github.com/Sammie156/go-pastemyst"
+)
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.loadUser(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user.User)
+}
+
+// getUserPastes lists a user's pastes newest first, pinned ones on top.
+// Private pastes are only listed to their owner.
+func (s *Server) getUserPastes(w http.ResponseWriter, r *http.Request) {
+	requester, ok := s.requestUser(w, r, false)
+	if !ok {
+		return
+	}
+
+	user, ok := s.loadUser(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if page < 0 {
+		page = 0
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	tag := query.Get("tag")
+
+	records, err := s.store.ListPastes(r.Context(), user.User.ID)
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+
+	self := requester != nil && requester.User.ID == user.User.ID
+	now := time.Now()
+
+	pastes := []gopastemyst.Paste{}
+	for _, record := range records {
+		if expired(record, now) || (record.Paste.Private && !self) {
+			continue
+		}
+		if tag != "" && !slices.Contains(record.Paste.Tags, tag) {
+			continue
+		}
+		pastes = append(pastes, record.Paste)
+	}
+
+	slices.SortFunc(pastes, func(a, b gopastemyst.Paste) int {
+		if a.Pinned != b.Pinned {
+			if a.Pinned {
+				return -1
+			}
+			return 1
+		}
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	totalPages := (len(pastes) + pageSize - 1) / pageSize
+	start := min(page*pageSize, len(pastes))
+	end := min(start+pageSize, len(pastes))
+
+	writeJSON(w, http.StatusOK, gopastemyst.PastePage{
+		Items:       pastes[start:end],
+		CurrentPage: page,
+		PageSize:    pageSize,
+		TotalPages:  totalPages,
+		HasNextPage: page+1 < totalPages,
+	})
+}
+
+// getUserTags lists the tags on a user's pastes, only to that user.
+func (s *Server) getUserTags(w http.ResponseWriter, r *http.Request) {
+	requester, ok := s.requestUser(w, r, true)
+	if !ok {
+		return
+	}
+
+	user, ok := s.loadUser(w, r)
+	if !ok {
+		return
+	}
+	if requester.User.ID != user.User.ID {
+		writeError(w, http.StatusForbidden, "tags are only listed to their owner")
+		return
+	}
+
+	records, err := s.store.ListPastes(r.Context(), user.User.ID)
+	if err != nil {
+		s.internalError(w, err)
+		return
+	}
+
+	tags := []string{}
+	now := time.Now()
+	for _, record := range records {
+		if expired(record, now) {
+			continue
+		}
+		for _, tag := range record.Paste.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	slices.Sort(tags)
+
+	writeJSON(w, http.StatusOK, tags)
+}
+
+func (s *Server) loadUser(w http.ResponseWriter, r *http.Request) (*UserRecord, bool) {
+	user, err := s.store.GetUser(r.Context(), r.PathValue("username"))
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "user not found")
+		return nil, false
+	}
+	if err != nil {
+		s.internalError(w, err)
+		return nil, false
+	}
+
+	return user, true
+}
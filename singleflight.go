@@ -0,0 +1,119 @@
+package gopastemyst
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// flightGroup collapses concurrent identical requests into a single HTTP
+// call. The shared call runs detached from any one caller's context and is
+// only cancelled once every caller waiting on it has given up.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	body    []byte
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+
+	f, ok := g.calls[key]
+	if ok {
+		f.waiters++
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{
+			done:    make(chan struct{}),
+			waiters: 1,
+			cancel:  cancel,
+		}
+		g.calls[key] = f
+
+		go func() {
+			body, err := fn(callCtx)
+
+			g.mu.Lock()
+			if g.calls[key] == f {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+
+			f.body, f.err = body, err
+			close(f.done)
+			cancel()
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		if f.err != nil {
+			return nil, f.err
+		}
+		// Every caller gets its own copy of the body
+		return bytes.Clone(f.body), nil
+
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Nobody is interested anymore, so stop the request and let the
+			// next caller start a fresh one
+			if g.calls[key] == f {
+				delete(g.calls, key)
+			}
+			f.cancel()
+		}
+		g.mu.Unlock()
+
+		return nil, ctx.Err()
+	}
+}
+
+// get performs a GET request for url, sharing the response with any other
+// goroutine requesting the same URL at the same time.
+func (c *Client) get(ctx context.Context, url string, opts []RequestOption) ([]byte, error) {
+	token, err := c.requestToken(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	key := flightKey(false, url, token, newRequestConfig(opts))
+
+	return c.flights.do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		return c.fetch(ctx, url, "", opts)
+	})
+}
+
+// flightKey identifies the GET requests for url that may share a response.
+// Cached and uncached requests never share one, since uncached callers want a
+// response fresher than the cache's. Neither do calls with different
+// credentials or headers, which may see different responses, or with
+// different timeouts, which would fail together with the shortest one.
+func flightKey(cached bool, url string, token string, cfg requestConfig) string {
+	key := http.MethodGet + " " + cacheKey(url, token)
+	if cached {
+		key = "cached " + key
+	}
+	if cfg.timeout > 0 {
+		key += " timeout=" + cfg.timeout.String()
+	}
+	if len(cfg.header) > 0 {
+		key += " " + fmt.Sprint(cfg.header)
+	}
+
+	return key
+}
@@ -0,0 +1,56 @@
+package gopastemyst
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ComputeStats computes the stats GetPasteStats returns for paste, without
+// asking the API. Pasties are keyed by ID.
+//
+// Bytes counts UTF-8 encoded bytes. Lines counts lines the way an editor
+// shows them: empty content has none, and a trailing newline doesn't start
+// another one. Words are runs of non-whitespace characters.
+func ComputeStats(paste Paste) Stats {
+	stats := Stats{Pasties: make(map[string]PastyStats, len(paste.Pasties))}
+
+	for _, pasty := range paste.Pasties {
+		stats.add(pasty.ID, ComputePastyStats(pasty.Content))
+	}
+
+	return stats
+}
+
+// ComputeCreateStats is ComputeStats for a paste that isn't created yet.
+// Pasties have no ID, so they are keyed by their index in options.Pasties.
+func ComputeCreateStats(options CreatePasteOptions) Stats {
+	stats := Stats{Pasties: make(map[string]PastyStats, len(options.Pasties))}
+
+	for i, pasty := range options.Pasties {
+		stats.add(strconv.Itoa(i), ComputePastyStats(pasty.Content))
+	}
+
+	return stats
+}
+
+// ComputePastyStats computes the stats of a single pasty's content, counted
+// like ComputeStats does.
+func ComputePastyStats(content string) PastyStats {
+	lines := 0
+	if content != "" {
+		lines = strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+	}
+
+	return PastyStats{
+		Bytes: len(content),
+		Lines: lines,
+		Words: len(strings.Fields(content)),
+	}
+}
+
+func (s *Stats) add(key string, pasty PastyStats) {
+	s.Pasties[key] = pasty
+	s.Bytes += pasty.Bytes
+	s.Lines += pasty.Lines
+	s.Words += pasty.Words
+}
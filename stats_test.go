@@ -0,0 +1,132 @@
+package gopastemyst
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Directory holding responses of the public API's stats endpoint, recorded
+// with TestRecordStatsFixtures
+const statsFixtureDir = "testdata/stats"
+
+// statsFixture is a paste created on the public API along with the stats the
+// API returned for it.
+type statsFixture struct {
+	Paste Paste `json:"paste"`
+	Stats Stats `json:"stats"`
+}
+
+// Content recorded by TestRecordStatsFixtures, by fixture name
+var statsFixtureContent = map[string][]string{
+	"empty":            {""},
+	"single-line":      {"hello world"},
+	"trailing-newline": {"a\n"},
+	"blank-lines":      {"a\n\n\nb\n\n"},
+	"crlf":             {"first line\r\nsecond line\r\n"},
+	"multibyte":        {"héllo wörld\n日本語 テキスト\n🎉"},
+	"several-pasties":  {"one\ntwo", "three four\n", ""},
+}
+
+func TestComputePastyStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    PastyStats
+	}{
+		{"empty", "", PastyStats{Bytes: 0, Lines: 0, Words: 0}},
+		{"single line", "hello world", PastyStats{Bytes: 11, Lines: 1, Words: 2}},
+		{"trailing newline", "a\n", PastyStats{Bytes: 2, Lines: 1, Words: 1}},
+		{"only a newline", "\n", PastyStats{Bytes: 1, Lines: 1, Words: 0}},
+		{"blank lines", "a\n\n\nb\n\n", PastyStats{Bytes: 7, Lines: 5, Words: 2}},
+		{"crlf", "first line\r\nsecond line\r\n", PastyStats{Bytes: 25, Lines: 2, Words: 4}},
+		{"multibyte", "héllo wörld\n日本語 テキスト\n🎉", PastyStats{Bytes: 41, Lines: 3, Words: 5}},
+		{"whitespace only", " \t \n", PastyStats{Bytes: 4, Lines: 1, Words: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputePastyStats(tt.content); got != tt.want {
+				t.Errorf("ComputePastyStats(%q) = %+v, want %+v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeStatsMatchesAPI(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(statsFixtureDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("no recorded stats fixtures, record them with PASTEMYST_RECORD_STATS=1")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			var fixture statsFixture
+			if err := readJSONFile(file, &fixture); err != nil {
+				t.Fatal(err)
+			}
+
+			got := ComputeStats(fixture.Paste)
+			if got.Bytes != fixture.Stats.Bytes || got.Lines != fixture.Stats.Lines || got.Words != fixture.Stats.Words {
+				t.Errorf("ComputeStats totals = %d bytes, %d lines, %d words, API returned %d bytes, %d lines, %d words",
+					got.Bytes, got.Lines, got.Words, fixture.Stats.Bytes, fixture.Stats.Lines, fixture.Stats.Words)
+			}
+
+			for id, want := range fixture.Stats.Pasties {
+				if pasty := got.Pasties[id]; pasty != want {
+					t.Errorf("pasty %s: ComputeStats = %+v, API returned %+v", id, pasty, want)
+				}
+			}
+		})
+	}
+}
+
+// TestRecordStatsFixtures creates a short-lived anonymous paste on the public
+// API for every entry of statsFixtureContent and writes its stats to
+// statsFixtureDir. It only runs with PASTEMYST_RECORD_STATS=1.
+func TestRecordStatsFixtures(t *testing.T) {
+	if os.Getenv("PASTEMYST_RECORD_STATS") != "1" {
+		t.Skip("set PASTEMYST_RECORD_STATS=1 to record stats fixtures from the public API")
+	}
+
+	client := NewClient("")
+
+	for name, contents := range statsFixtureContent {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			options := CreatePasteOptions{Title: "go-pastemyst stats fixture", ExpiresIn: "1h"}
+			for _, content := range contents {
+				options.Pasties = append(options.Pasties, CreatePastyOptions{Content: content})
+			}
+
+			paste, err := client.CreatePaste(ctx, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stats, err := client.GetPasteStats(ctx, paste.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.MarshalIndent(statsFixture{Paste: *paste, Stats: *stats}, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.MkdirAll(statsFixtureDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(statsFixtureDir, name+".json"), append(data, '\n'), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package gopastemyst
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"unicode/utf8"
+)
+
+// DefaultMaxPasteSize is the content size limit CreatePasteFromReader applies
+// when none is given, in bytes across all pasties.
+const DefaultMaxPasteSize = 5 << 20
+
+// ErrContentTooLarge is returned when paste content goes over the size limit.
+var ErrContentTooLarge = errors.New("paste content exceeds the size limit")
+
+// WithMaxPasteSize makes the client use size instead of DefaultMaxPasteSize as
+// the content size limit of CreatePasteFromReader and CreateChunkedPaste,
+// for instances accepting larger pastes than the public one. Sizes that
+// aren't positive are ignored.
+func WithMaxPasteSize(size int64) ClientOption {
+	return func(c *Client) {
+		if size > 0 {
+			c.maxPasteSize = size
+		}
+	}
+}
+
+// maxSize returns the content size limit of the client.
+func (c *Client) maxSize() int64 {
+	if c.maxPasteSize > 0 {
+		return c.maxPasteSize
+	}
+
+	return DefaultMaxPasteSize
+}
+
+// CreatePastyFromReaderOptions is like CreatePastyOptions, with the content
+// read from a reader instead of held in memory.
+type CreatePastyFromReaderOptions struct {
+	Title    string
+	Language string
+	Content  io.Reader
+}
+
+// CreatePasteFromReaderOptions is like CreatePasteOptions, for pasties whose
+// content is streamed.
+type CreatePasteFromReaderOptions struct {
+	Title     string
+	ExpiresIn string
+	Anonymous bool
+	Private   bool
+	Pinned    bool
+	Encrypted bool
+	Tags      []string
+	Pasties   []CreatePastyFromReaderOptions
+
+	// Limit on the content of all pasties together. Zero means the client's
+	// limit, see WithMaxPasteSize, and a negative value disables the check.
+	MaxSize int64
+
+	// Called with the number of content bytes sent so far, or read so far
+	// when the content is read into memory first
+	OnProgress func(sent int64)
+}
+
+// CreatePasteFromReader creates a paste like CreatePaste, but streams the
+// request body instead of building it in memory, so large content is only
+// read once and never held whole. Upload stops with ErrContentTooLarge as
+// soon as the content goes over MaxSize.
+//
+// Secret scanning, dedup and idempotency keys need the whole content, so when
+// the client has a secret scanner, see WithSecretScanner, or a dedup store,
+// see WithDedup, or the call has an idempotency key, see WithIdempotencyKey,
+// the content is read into memory, still up to MaxSize, and sent with
+// CreatePaste instead.
+func (c *Client) CreatePasteFromReader(ctx context.Context, options CreatePasteFromReaderOptions, opts ...RequestOption) (*Paste, error) {
+	if len(options.Pasties) == 0 {
+		return nil, fmt.Errorf("at least one pasty should be present")
+	}
+
+	limit := options.MaxSize
+	if limit == 0 {
+		limit = c.maxSize()
+	}
+
+	cfg := newRequestConfig(opts)
+	if c.scanner != nil || (c.dedup != nil && !cfg.noDedup) || (cfg.idempotencyKey != "" && c.idempotency != nil) {
+		buffered, err := readStreamedPaste(options, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.CreatePaste(ctx, buffered, opts...)
+	}
+
+	url := fmt.Sprintf("%s/pastes", c.baseURL)
+
+	body, writer := io.Pipe()
+	encodeErr := make(chan error, 1)
+
+	go func() {
+		err := encodeStreamedPaste(writer, options, limit)
+		writer.CloseWithError(err)
+		encodeErr <- err
+	}()
+
+	req, err := c.newRequest(ctx, http.MethodPost, url, body, false, opts)
+	if err != nil {
+		body.CloseWithError(err)
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.do(req, opts)
+	if err != nil {
+		body.CloseWithError(err)
+		if encErr := <-encodeErr; encErr != nil && !errors.Is(encErr, io.ErrClosedPipe) {
+			return nil, encErr
+		}
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	// The server may answer before reading the whole body, so make sure the
+	// encoder isn't left blocked on the pipe
+	body.CloseWithError(io.ErrClosedPipe)
+	if encErr := <-encodeErr; encErr != nil && !errors.Is(encErr, io.ErrClosedPipe) {
+		return nil, encErr
+	}
+
+	if err := checkResponse(res, http.StatusCreated); err != nil {
+		return nil, err
+	}
+
+	var newPaste Paste
+	if err := json.NewDecoder(res.Body).Decode(&newPaste); err != nil {
+		return nil, fmt.Errorf("could not decode json response: %w", err)
+	}
+
+	return &newPaste, nil
+}
+
+// readStreamedPaste reads the content of every pasty into the options of a
+// regular create request.
+func readStreamedPaste(options CreatePasteFromReaderOptions, limit int64) (CreatePasteOptions, error) {
+	created := CreatePasteOptions{
+		Title:     options.Title,
+		ExpiresIn: options.ExpiresIn,
+		Anonymous: options.Anonymous,
+		Private:   options.Private,
+		Pinned:    options.Pinned,
+		Encrypted: options.Encrypted,
+		Tags:      options.Tags,
+	}
+
+	var read int64
+	for _, pasty := range options.Pasties {
+		var content []byte
+		if pasty.Content != nil {
+			reader := pasty.Content
+			if limit >= 0 {
+				// One byte over the limit is enough to know it was exceeded
+				reader = io.LimitReader(reader, limit-read+1)
+			}
+
+			var err error
+			if content, err = io.ReadAll(reader); err != nil {
+				return CreatePasteOptions{}, err
+			}
+		}
+
+		read += int64(len(content))
+		if limit >= 0 && read > limit {
+			return CreatePasteOptions{}, ErrContentTooLarge
+		}
+		if options.OnProgress != nil {
+			options.OnProgress(read)
+		}
+
+		created.Pasties = append(created.Pasties, CreatePastyOptions{
+			Title:    pasty.Title,
+			Language: pasty.Language,
+			Content:  string(content),
+		})
+	}
+
+	return created, nil
+}
+
+// encodeStreamedPaste writes the JSON body of a create request to w, copying
+// pasty content from the readers as it goes.
+func encodeStreamedPaste(w io.Writer, options CreatePasteFromReaderOptions, limit int64) error {
+	header, err := json.Marshal(CreatePasteOptions{
+		Title:     options.Title,
+		ExpiresIn: options.ExpiresIn,
+		Anonymous: options.Anonymous,
+		Private:   options.Private,
+		Pinned:    options.Pinned,
+		Encrypted: options.Encrypted,
+		Tags:      options.Tags,
+	})
+	if err != nil {
+		return fmt.Errorf("could not marshal paste options: %w", err)
+	}
+
+	// Reuse the regular encoding for every field but the pasties, which are
+	// always last and written by hand
+	header = bytes.TrimSuffix(header, []byte(`"pasties":null}`))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `"pasties":[`); err != nil {
+		return err
+	}
+
+	content := &jsonStringWriter{w: w, limit: limit, onProgress: options.OnProgress}
+
+	for i, pasty := range options.Pasties {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+
+		fields, err := json.Marshal(struct {
+			Title    string `json:"title,omitempty"`
+			Language string `json:"language,omitempty"`
+		}{pasty.Title, pasty.Language})
+		if err != nil {
+			return fmt.Errorf("could not marshal pasty options: %w", err)
+		}
+
+		// Reopens the object so the content can follow the other fields
+		fields = bytes.TrimSuffix(fields, []byte("}"))
+		if len(fields) > 1 {
+			fields = append(fields, ',')
+		}
+		if _, err := w.Write(fields); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, `"content":"`); err != nil {
+			return err
+		}
+
+		if pasty.Content != nil {
+			if _, err := io.Copy(content, pasty.Content); err != nil {
+				return err
+			}
+		}
+		if err := content.flush(); err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, `"}`); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(w, "]}")
+	return err
+}
+
+// jsonStringWriter writes everything written to it as the inside of a JSON
+// string, escaping as needed, and enforces the content size limit. Invalid
+// UTF-8 is replaced with U+FFFD, like encoding/json does.
+type jsonStringWriter struct {
+	w          io.Writer
+	limit      int64
+	written    int64
+	onProgress func(int64)
+	buf        []byte
+
+	// Start of a UTF-8 sequence the reader split, completed by the next write
+	pending []byte
+}
+
+const hexDigits = "0123456789abcdef"
+
+func (j *jsonStringWriter) Write(p []byte) (int, error) {
+	if j.limit >= 0 && j.written+int64(len(p)) > j.limit {
+		return 0, ErrContentTooLarge
+	}
+
+	data := p
+	if len(j.pending) > 0 {
+		data = append(j.pending, p...)
+		j.pending = nil
+	}
+
+	j.buf = j.buf[:0]
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch {
+		case b == '"' || b == '\\':
+			j.buf = append(j.buf, '\\', b)
+		case b == '\n':
+			j.buf = append(j.buf, '\\', 'n')
+		case b == '\r':
+			j.buf = append(j.buf, '\\', 'r')
+		case b == '\t':
+			j.buf = append(j.buf, '\\', 't')
+		case b < 0x20:
+			j.buf = append(j.buf, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xf])
+		case b < utf8.RuneSelf:
+			j.buf = append(j.buf, b)
+		default:
+			r, size := utf8.DecodeRune(data[i:])
+			switch {
+			case r != utf8.RuneError || size > 1:
+				j.buf = append(j.buf, data[i:i+size]...)
+				i += size
+				continue
+			case !utf8.FullRune(data[i:]):
+				// The rest of the sequence may come with the next write
+				j.pending = bytes.Clone(data[i:])
+				i = len(data)
+				continue
+			default:
+				j.buf = append(j.buf, `\ufffd`...)
+			}
+		}
+		i++
+	}
+
+	if _, err := j.w.Write(j.buf); err != nil {
+		return 0, err
+	}
+
+	j.written += int64(len(p))
+	if j.onProgress != nil {
+		j.onProgress(j.written)
+	}
+
+	return len(p), nil
+}
+
+// flush ends the current string, replacing a sequence the content ended in
+// the middle of.
+func (j *jsonStringWriter) flush() error {
+	if len(j.pending) == 0 {
+		return nil
+	}
+
+	j.buf = j.buf[:0]
+	for range j.pending {
+		j.buf = append(j.buf, `\ufffd`...)
+	}
+	j.pending = nil
+
+	_, err := j.w.Write(j.buf)
+	return err
+}
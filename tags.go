@@ -0,0 +1,57 @@
+package gopastemyst
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// SetTags replaces all tags of a paste.
+func (c *Client) SetTags(ctx context.Context, pasteID string, tags []string, opts ...RequestOption) (*Paste, error) {
+	return c.modifyPaste(ctx, pasteID, opts, func(_ *Paste, options *EditPasteOptions) error {
+		normalized := normalizeTags(tags)
+		options.Tags = &normalized
+		return nil
+	})
+}
+
+// AddTags adds tags to a paste, keeping the ones it already has.
+func (c *Client) AddTags(ctx context.Context, pasteID string, tags []string, opts ...RequestOption) (*Paste, error) {
+	return c.modifyPaste(ctx, pasteID, opts, func(current *Paste, options *EditPasteOptions) error {
+		merged := normalizeTags(append(slices.Clone(current.Tags), tags...))
+		options.Tags = &merged
+		return nil
+	})
+}
+
+// RemoveTags removes tags from a paste. Tags the paste doesn't have are
+// ignored.
+func (c *Client) RemoveTags(ctx context.Context, pasteID string, tags []string, opts ...RequestOption) (*Paste, error) {
+	return c.modifyPaste(ctx, pasteID, opts, func(current *Paste, options *EditPasteOptions) error {
+		remove := normalizeTags(tags)
+
+		kept := []string{}
+		for _, tag := range normalizeTags(current.Tags) {
+			if !slices.Contains(remove, tag) {
+				kept = append(kept, tag)
+			}
+		}
+
+		options.Tags = &kept
+		return nil
+	})
+}
+
+// normalizeTags trims tags and drops empty and duplicate ones, keeping the
+// order of first appearance.
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+
+	return normalized
+}
@@ -96,6 +96,10 @@ type EditPastyOptions struct {
 type EditPasteOptions struct {
 	Title   string             `json:"title,omitempty"`
 	Pasties []EditPastyOptions `json:"pasties"`
+
+	// Replaces the tags of the paste when set. Nil leaves them unchanged, a
+	// pointer to an empty slice removes them all.
+	Tags *[]string `json:"tags,omitempty"`
 }
 
 // ----- USER TYPES -----
@@ -114,3 +118,11 @@ type GetUserPasteOptions struct {
 	PageSize int
 	Tag      string
 }
+
+type PastePage struct {
+	Items       []Paste `json:"items"`
+	CurrentPage int     `json:"currentPage"`
+	PageSize    int     `json:"pageSize"`
+	TotalPages  int     `json:"totalPages"`
+	HasNextPage bool    `json:"hasNextPage"`
+}
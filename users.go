@@ -4,45 +4,110 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
 // Check : https://docs.beta.myst.rs/users
 
-func (c *Client) GetUser(ctx context.Context, username string) (*User, error) {
+func (c *Client) GetUser(ctx context.Context, username string, opts ...RequestOption) (*User, error) {
 	url := fmt.Sprintf("%s/users/%s", c.baseURL, username)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	body, err := c.get(ctx, url, opts)
 	if err != nil {
-		return nil, fmt.Errorf("could not request http error: %w", err)
+		return nil, err
 	}
 
-	res, err := c.httpClient.Do(req)
+	var user User
+	if err := json.Unmarshal(body, &user); err != nil {
+		return nil, fmt.Errorf("could not decode JSON response: %w", err)
+	}
+
+	return &user, nil
+}
+
+// func (c *Client)
+
+func (c *Client) GetUserPastes(ctx context.Context, username string, options GetUserPasteOptions, opts ...RequestOption) (*PastePage, error) {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(options.Page))
+	if options.PageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(options.PageSize))
+	}
+	if options.Tag != "" {
+		query.Set("tag", options.Tag)
+	}
+
+	url := fmt.Sprintf("%s/users/%s/pastes?%s", c.baseURL, username, query.Encode())
+
+	// Private pastes are only listed for their owner
+	req, err := c.newRequest(ctx, http.MethodGet, url, nil, false, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(req, opts)
 	if err != nil {
 		return nil, fmt.Errorf("http request failed: %w", err)
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		var apiError APIError
+	if err := checkResponse(res, http.StatusOK); err != nil {
+		return nil, err
+	}
 
-		if err := json.NewDecoder(res.Body).Decode(&apiError); err != nil {
-			return nil, fmt.Errorf("API error (%s): %s", res.Status, apiError.StatusMessage)
-		}
+	var page PastePage
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("could not decode JSON response: %w", err)
+	}
+
+	return &page, nil
+}
+
+// GetAllUserPastes walks every page of a user's pastes, optionally filtered
+// by tag, and returns them together.
+func (c *Client) GetAllUserPastes(ctx context.Context, username string, tag string, opts ...RequestOption) ([]Paste, error) {
+	var pastes []Paste
 
-		bodyBytes, err := io.ReadAll(res.Body)
+	for page := 0; ; page++ {
+		result, err := c.GetUserPastes(ctx, username, GetUserPasteOptions{Page: page, Tag: tag}, opts...)
 		if err != nil {
-			return nil, fmt.Errorf("API returned non-200 status: %s, body(%s)", res.Status, string(bodyBytes))
+			return nil, err
+		}
+
+		pastes = append(pastes, result.Items...)
+
+		if !result.HasNextPage || len(result.Items) == 0 {
+			return pastes, nil
 		}
 	}
+}
 
-	var user User
-	if err := json.NewDecoder(res.Body).Decode(&user); err != nil {
+// GetUserTags lists the tags used on a user's pastes. The API only lists the
+// tags of the user the token belongs to.
+func (c *Client) GetUserTags(ctx context.Context, username string, opts ...RequestOption) ([]string, error) {
+	url := fmt.Sprintf("%s/users/%s/tags", c.baseURL, username)
+
+	req, err := c.newRequest(ctx, http.MethodGet, url, nil, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(req, opts)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if err := checkResponse(res, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	if err := json.NewDecoder(res.Body).Decode(&tags); err != nil {
 		return nil, fmt.Errorf("could not decode JSON response: %w", err)
 	}
 
-	return &user, nil
+	return tags, nil
 }
-
-// func (c *Client)
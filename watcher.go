@@ -0,0 +1,341 @@
+package gopastemyst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults for WatcherOptions
+const (
+	DefaultWatchMinInterval = 30 * time.Second
+	DefaultWatchMaxInterval = 10 * time.Minute
+)
+
+type WatchEventType int
+
+const (
+	// The paste was edited, HistoryID holds the new revision
+	WatchEdited WatchEventType = iota + 1
+	// The number of stars on the paste changed
+	WatchStarsChanged
+	// The paste expired or was deleted, it is no longer watched afterwards.
+	// A paste made private by someone else is gone as well, since the API
+	// answers as if it didn't exist.
+	WatchGone
+	// The paste was made private and the watcher's token can still see it
+	WatchMadePrivate
+)
+
+func (t WatchEventType) String() string {
+	switch t {
+	case WatchEdited:
+		return "edited"
+	case WatchStarsChanged:
+		return "stars changed"
+	case WatchGone:
+		return "gone"
+	case WatchMadePrivate:
+		return "made private"
+	default:
+		return fmt.Sprintf("WatchEventType(%d)", int(t))
+	}
+}
+
+// WatchEvent describes a change noticed by a Watcher. Paste is the state of
+// the paste after the change and Previous the state seen on the poll before.
+// Paste is nil for WatchGone events.
+type WatchEvent struct {
+	Type      WatchEventType
+	PasteID   string
+	HistoryID string
+	Paste     *Paste
+	Previous  *Paste
+}
+
+type WatcherOptions struct {
+	// Polling interval used right after a change is seen. Pastes that stay
+	// unchanged are polled less often, doubling up to MaxInterval.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// Called for every event instead of sending it on the Events channel
+	OnEvent func(WatchEvent)
+
+	// Called when polling a paste fails for a reason other than it being gone
+	OnError func(pasteID string, err error)
+}
+
+// Watcher polls a set of pastes and reports changes to them. Create one with
+// Client.NewWatcher and start it with Run.
+type Watcher struct {
+	client *Client
+	opts   WatcherOptions
+	events chan WatchEvent
+	wake   chan struct{}
+
+	mu      sync.Mutex
+	watched map[string]*watchState
+}
+
+type watchState struct {
+	last     *Paste
+	interval time.Duration
+	next     time.Time
+}
+
+// NewWatcher creates a Watcher for pasteIDs. More pastes can be added later
+// with Add.
+func (c *Client) NewWatcher(opts WatcherOptions, pasteIDs ...string) *Watcher {
+	if opts.MinInterval <= 0 {
+		opts.MinInterval = DefaultWatchMinInterval
+	}
+	if opts.MaxInterval < opts.MinInterval {
+		opts.MaxInterval = max(DefaultWatchMaxInterval, opts.MinInterval)
+	}
+
+	w := &Watcher{
+		client:  c,
+		opts:    opts,
+		events:  make(chan WatchEvent, 16),
+		wake:    make(chan struct{}, 1),
+		watched: make(map[string]*watchState),
+	}
+
+	for _, id := range pasteIDs {
+		w.Add(id)
+	}
+
+	return w
+}
+
+// Events returns the channel events are delivered on when no OnEvent
+// callback is set. It is closed when Run returns.
+func (w *Watcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// Add starts watching pasteID. The first poll only records the current state
+// of the paste and does not produce events.
+func (w *Watcher) Add(pasteID string) {
+	w.mu.Lock()
+	if _, ok := w.watched[pasteID]; !ok {
+		w.watched[pasteID] = &watchState{interval: w.opts.MinInterval}
+	}
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Remove stops watching pasteID.
+func (w *Watcher) Remove(pasteID string) {
+	w.mu.Lock()
+	delete(w.watched, pasteID)
+	w.mu.Unlock()
+}
+
+// Run polls the watched pastes until ctx is cancelled, then closes the Events
+// channel and returns the context's error.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer close(w.events)
+
+	for {
+		for _, id := range w.due(time.Now()) {
+			if err := w.poll(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		timer := time.NewTimer(w.untilNext(time.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-w.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *Watcher) due(now time.Time) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var ids []string
+	for id, state := range w.watched {
+		if !now.Before(state.next) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func (w *Watcher) untilNext(now time.Time) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wait := w.opts.MaxInterval
+	for _, state := range w.watched {
+		wait = min(wait, state.next.Sub(now))
+	}
+
+	return max(wait, 0)
+}
+
+// poll fetches one paste and emits events for whatever changed since the
+// previous poll. It only returns an error when ctx is done.
+func (w *Watcher) poll(ctx context.Context, pasteID string) error {
+	w.mu.Lock()
+	state, ok := w.watched[pasteID]
+	if !ok {
+		w.mu.Unlock()
+		return nil
+	}
+	previous := state.last
+	w.mu.Unlock()
+
+	// Polls skip the response cache so changes are seen as soon as possible
+	current, err := w.client.fetchPaste(ctx, pasteID, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if errors.Is(err, ErrNotFound) {
+			w.Remove(pasteID)
+			return w.emit(ctx, WatchEvent{Type: WatchGone, PasteID: pasteID, Previous: previous})
+		}
+
+		if w.opts.OnError != nil {
+			w.opts.OnError(pasteID, err)
+		}
+		w.reschedule(pasteID, false)
+		return nil
+	}
+
+	if current.DeletesAt != nil && !current.DeletesAt.After(time.Now()) {
+		w.Remove(pasteID)
+		return w.emit(ctx, WatchEvent{Type: WatchGone, PasteID: pasteID, Previous: previous})
+	}
+
+	w.mu.Lock()
+	if state, ok := w.watched[pasteID]; ok {
+		state.last = current
+	}
+	w.mu.Unlock()
+
+	if previous == nil {
+		w.reschedule(pasteID, false)
+		return nil
+	}
+
+	var events []WatchEvent
+
+	if editedSince(previous, current) {
+		events = append(events, w.editEvents(ctx, previous, current)...)
+	}
+	if current.Stars != previous.Stars {
+		events = append(events, WatchEvent{Type: WatchStarsChanged, PasteID: pasteID, Paste: current, Previous: previous})
+	}
+	if current.Private && !previous.Private {
+		events = append(events, WatchEvent{Type: WatchMadePrivate, PasteID: pasteID, Paste: current, Previous: previous})
+	}
+
+	w.reschedule(pasteID, len(events) > 0)
+
+	for _, event := range events {
+		if err := w.emit(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func editedSince(previous, current *Paste) bool {
+	if current.EditedAt == nil {
+		return false
+	}
+
+	return previous.EditedAt == nil || current.EditedAt.After(*previous.EditedAt)
+}
+
+// editEvents returns one WatchEdited event per revision made since previous,
+// oldest first. When the history can't be loaded a single event without a
+// history ID is returned instead.
+func (w *Watcher) editEvents(ctx context.Context, previous, current *Paste) []WatchEvent {
+	history, err := w.client.GetCompactPasteHistory(ctx, current.ID)
+	if err != nil {
+		if w.opts.OnError != nil {
+			w.opts.OnError(current.ID, err)
+		}
+		return []WatchEvent{{Type: WatchEdited, PasteID: current.ID, Paste: current, Previous: previous}}
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].EditedAt.Before(history[j].EditedAt)
+	})
+
+	var events []WatchEvent
+	for _, entry := range history {
+		if previous.EditedAt != nil && !entry.EditedAt.After(*previous.EditedAt) {
+			continue
+		}
+
+		events = append(events, WatchEvent{
+			Type:      WatchEdited,
+			PasteID:   current.ID,
+			HistoryID: entry.ID,
+			Paste:     current,
+			Previous:  previous,
+		})
+	}
+
+	if len(events) == 0 {
+		events = append(events, WatchEvent{Type: WatchEdited, PasteID: current.ID, Paste: current, Previous: previous})
+	}
+
+	return events
+}
+
+// reschedule sets the next poll time of pasteID, going back to the minimum
+// interval after a change and backing off otherwise.
+func (w *Watcher) reschedule(pasteID string, changed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	state, ok := w.watched[pasteID]
+	if !ok {
+		return
+	}
+
+	if changed {
+		state.interval = w.opts.MinInterval
+	} else if state.last != nil && !state.next.IsZero() {
+		state.interval = min(state.interval*2, w.opts.MaxInterval)
+	}
+	state.next = time.Now().Add(state.interval)
+}
+
+func (w *Watcher) emit(ctx context.Context, event WatchEvent) error {
+	if w.opts.OnEvent != nil {
+		w.opts.OnEvent(event)
+		return nil
+	}
+
+	select {
+	case w.events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}